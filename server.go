@@ -2,19 +2,128 @@ package main
 
 /*
 
-Run YACS processing via the command line.
+Run YACS HTTP server.
+
+Every flag may be set by the environment variable, flags are stronger:
+
+	-addr              YACS_ADDR
+	-root              YACS_ROOT
+	-htpasswd          YACS_HTPASSWD
+	-tokens-file       YACS_TOKENS_FILE
+	-tls-cert          YACS_TLS_CERT
+	-tls-key           YACS_TLS_KEY
+	-shutdown-timeout  YACS_SHUTDOWN_TIMEOUT
+	-watch-interval    YACS_WATCH_INTERVAL
+	-allow-hosts       YACS_ALLOW_HOSTS
+
+Single user may be set by YACS_USERNAME and YACS_PASSWORD, bearer tokens by YACS_TOKENS="token1,token2".
+Passwords and tokens are never taken from flags, so they don't appear in the list of processes.
+
+Documents are loaded from the root dir only. Remote "$ref", "@parent" and "@schemas" documents
+are loaded from hosts of -allow-hosts="example.com,configs.local:8080" only.
 
 */
 
 import (
+	"context"
+	"flag"
 	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
-	"github.com/iostrovok/yacs-go/httpserver"
+	"github.com/iostrovok/yacs-go/yacs-go/httpserver"
 )
 
-var rootDir string = ""
+func env(key, def string) string {
+	if v, find := os.LookupEnv(key); find {
+		return v
+	}
+	return def
+}
 
 func main() {
-	fmt.Println("Start 0.0.2...")
-	httpserver.Run(rootDir)
+
+	var cfg httpserver.Config
+	var htpasswd, tokensFile, allowHosts string
+	var noAuth bool
+
+	timeout, err := time.ParseDuration(env("YACS_SHUTDOWN_TIMEOUT", httpserver.DefaultShutdownTimeout.String()))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "YACS_SHUTDOWN_TIMEOUT: %s\n", err)
+		os.Exit(2)
+	}
+
+	interval, err := time.ParseDuration(env("YACS_WATCH_INTERVAL", httpserver.DefaultWatchInterval.String()))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "YACS_WATCH_INTERVAL: %s\n", err)
+		os.Exit(2)
+	}
+
+	flag.StringVar(&cfg.Addr, "addr", env("YACS_ADDR", httpserver.DefaultAddr), `Address to listen. Env: YACS_ADDR.`)
+	flag.StringVar(&cfg.RootDir, "root", env("YACS_ROOT", "."), `Dir of served configs. Env: YACS_ROOT.`)
+	flag.StringVar(&htpasswd, "htpasswd", env("YACS_HTPASSWD", ""), `htpasswd file of users of basic authentication, bcrypt or SHA1 hashes only. Env: YACS_HTPASSWD.`)
+	flag.StringVar(&tokensFile, "tokens-file", env("YACS_TOKENS_FILE", ""), `File of bearer tokens, one token by line. Env: YACS_TOKENS_FILE.`)
+	flag.BoolVar(&noAuth, "no-auth", false, `Serve requests without credentials if no credentials are set. (default "false")`)
+	flag.StringVar(&cfg.CertFile, "tls-cert", env("YACS_TLS_CERT", ""), `TLS certificate file, it turns on HTTPS with 'tls-key'. Env: YACS_TLS_CERT.`)
+	flag.StringVar(&cfg.KeyFile, "tls-key", env("YACS_TLS_KEY", ""), `TLS key file. Env: YACS_TLS_KEY.`)
+	flag.DurationVar(&cfg.ShutdownTimeout, "shutdown-timeout", timeout, `Time to finish active requests on SIGTERM. Env: YACS_SHUTDOWN_TIMEOUT.`)
+	flag.StringVar(&allowHosts, "allow-hosts", env("YACS_ALLOW_HOSTS", ""), `Comma separated hosts of remote documents, they are not loaded by default. Env: YACS_ALLOW_HOSTS.`)
+	flag.DurationVar(&cfg.WatchInterval, "watch-interval", interval, `Period of checks of dependencies of watched configs, see /watch/. Env: YACS_WATCH_INTERVAL.`)
+	flag.Parse()
+
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	cfg.Logger = logger
+
+	if allowHosts != "" {
+		cfg.AllowedHosts = strings.Split(allowHosts, ",")
+	}
+
+	auth := httpserver.AnyAuth{}
+
+	if htpasswd != "" {
+		a, err := httpserver.LoadHtpasswd(htpasswd)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		auth = append(auth, a)
+	}
+
+	if user := os.Getenv("YACS_USERNAME"); user != "" {
+		pass := os.Getenv("YACS_PASSWORD")
+		if pass == "" {
+			logger.Fatal("YACS_PASSWORD is empty: set the password of YACS_USERNAME")
+		}
+		auth = append(auth, httpserver.NewBasicAuth(user, pass))
+	}
+
+	if tokensFile != "" {
+		a, err := httpserver.LoadTokens(tokensFile)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		auth = append(auth, a)
+	}
+
+	if tokens := os.Getenv("YACS_TOKENS"); tokens != "" {
+		auth = append(auth, httpserver.NewTokenAuth(strings.Split(tokens, ",")...))
+	}
+
+	switch {
+	case len(auth) > 0:
+		cfg.Auth = auth
+	case !noAuth:
+		logger.Fatal("no credentials: set -htpasswd, -tokens-file, YACS_USERNAME/YACS_PASSWORD or YACS_TOKENS, or -no-auth")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := httpserver.Run(ctx, cfg); err != nil {
+		logger.Fatal(err)
+	}
+	logger.Print("HTTP server has been stopped")
 }
@@ -0,0 +1,286 @@
+package batch
+
+/*
+
+Batch processing of many files by the pool of workers.
+
+Example usage:
+
+	files, err := utils.FindAllFiles("./configs", "./out", "")
+	...
+	report := batch.Run(ctx, helper.NewProcessor(), files, batch.Options{
+		Workers: 4,
+		Format:  format.JSON,
+	})
+
+	for _, f := range report.Failures {
+		fmt.Println(f)
+	}
+
+All files are processed by default, valid results are written even if other files have failed.
+Options.FailFast stops the batch on the first failure.
+Options.Manifest turns on incremental builds, see manifest.go.
+
+*/
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/iostrovok/yacs-go/yacs-go/format"
+	"github.com/iostrovok/yacs-go/yacs-go/helper"
+	"github.com/iostrovok/yacs-go/yacs-go/utils"
+)
+
+// Steps of processing of single file besides steps of helper.Processor.
+const (
+	StageEncode = "encode"
+	StageWrite  = "write"
+)
+
+// DefaultMode is used if Options.Mode is zero.
+const DefaultMode = os.FileMode(0777)
+
+// Options of batch processing.
+type Options struct {
+	// Workers is the number of goroutines, 1 is used if it's less than 1.
+	Workers int
+	// Format of results, extensions of result files are changed by format.ReplaceExt.
+	Format format.Format
+	// Mode of result files and dirs.
+	Mode os.FileMode
+	// FailFast stops processing on the first failure.
+	FailFast bool
+	// Logger prints warnings, it may be nil.
+	Logger utils.Logger
+	// Start is called by workers before processing of each file, it may be nil.
+	Start func(file utils.FileForProcess)
+	// Done is called after each processed file one at a time, err is *Failure or nil. It may be nil.
+	Done func(file utils.FileForProcess, err error)
+
+	// Manifest is the file of dependencies of results. If it's set, only files which
+	// have been changed since the previous run are processed.
+	Manifest string
+	// Check is the way to find changes of dependencies, CheckMtime is used by default.
+	Check CheckMode
+	// Settings describe options of processing. All files are processed again if they are changed.
+	Settings string
+}
+
+func (opts Options) mode() os.FileMode {
+	if opts.Mode == 0 {
+		return DefaultMode
+	}
+	return opts.Mode
+}
+
+// Failure is the error of single file.
+type Failure struct {
+	File string
+	// Stage is "load", "resolve", "inherit", "validate", "encode" or "write".
+	Stage string
+	Err   error
+}
+
+func (f *Failure) Error() string {
+	return fmt.Sprintf("%s [%s]: %s", f.File, f.Stage, f.Err)
+}
+
+// Unwrap returns the original error.
+func (f *Failure) Unwrap() error {
+	return f.Err
+}
+
+// Report is the result of the batch.
+type Report struct {
+	// Total is the number of files of the batch.
+	Total int
+	// Processed is the number of files which have been processed with or without errors.
+	Processed int
+	// Skipped is the number of files which are up to date by the manifest.
+	Skipped int
+	// Written is the number of written results.
+	Written int
+	// Failures are sorted by files.
+	Failures []*Failure
+	// Canceled is true if the batch has been stopped by the context or by FailFast.
+	Canceled bool
+	Duration time.Duration
+	// Dependencies are sorted local files which results and failed files depend on, they are known by the manifest only.
+	Dependencies []string
+}
+
+// Err returns nil if all files have been written.
+func (r *Report) Err() error {
+	switch {
+	case len(r.Failures) > 0:
+		return fmt.Errorf("%d of %d files have failed", len(r.Failures), r.Total)
+	case r.Canceled:
+		return fmt.Errorf("the batch has been canceled after %d of %d files", r.Processed+r.Skipped, r.Total)
+	}
+	return nil
+}
+
+// ProcessFile processes single file and writes result. The error is *Failure.
+func ProcessFile(p *helper.Processor, from, to string, opts Options) error {
+	_, err := processFile(p, from, to, opts)
+	return err
+}
+
+// processFile returns the result to get its dependencies.
+func processFile(p *helper.Processor, from, to string, opts Options) (*helper.Output, error) {
+
+	res, err := p.ProcessURI(from)
+	if err != nil {
+		return nil, &Failure{File: from, Stage: helper.ErrorStage(err), Err: err}
+	}
+
+	if opts.Logger != nil {
+		for _, w := range res.Warnings {
+			opts.Logger.Printf("warning: %s: %s", from, w)
+		}
+	}
+
+	f := opts.Format
+	if f == "" {
+		f = format.JSON
+	}
+
+	body, err := format.Encode(f, res.Doc)
+	if err != nil {
+		return nil, &Failure{File: from, Stage: StageEncode, Err: err}
+	}
+
+	if err := utils.SaveFile(to, body, opts.mode()); err != nil {
+		return nil, &Failure{File: from, Stage: StageWrite, Err: err}
+	}
+
+	return res, nil
+}
+
+// Run processes files by workers until all files are done or ctx is canceled.
+func Run(ctx context.Context, p *helper.Processor, files []utils.FileForProcess, opts Options) *Report {
+
+	startTime := time.Now()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workers := opts.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	report := &Report{Total: len(files)}
+	var mu sync.Mutex
+
+	var deps *manifest
+	if opts.Manifest != "" {
+		check := opts.Check
+		if check == "" {
+			check = CheckMtime
+		}
+		deps = loadManifest(opts.Manifest, check, opts.Settings)
+	}
+
+	jobs := make(chan utils.FileForProcess)
+	wg := &sync.WaitGroup{}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for bf := range jobs {
+				// select of the producer may send one more file after cancel
+				if ctx.Err() != nil {
+					continue
+				}
+
+				if deps != nil && deps.upToDate(bf) {
+					mu.Lock()
+					report.Skipped++
+					mu.Unlock()
+					continue
+				}
+
+				if opts.Start != nil {
+					opts.Start(bf)
+				}
+
+				var before *snapshot
+				if deps != nil {
+					before = deps.snapshot(bf)
+				}
+
+				res, err := processFile(p, bf.From, bf.To, opts)
+				if deps != nil {
+					if err != nil {
+						deps.fail(bf, helper.ErrorDependencies(err.(*Failure).Err))
+					} else {
+						deps.set(bf, res.Dependencies, before)
+					}
+				}
+
+				mu.Lock()
+				report.Processed++
+				if err != nil {
+					report.Failures = append(report.Failures, err.(*Failure))
+					if opts.FailFast {
+						report.Canceled = true
+						cancel()
+					}
+				} else {
+					report.Written++
+				}
+				if opts.Done != nil {
+					opts.Done(bf, err)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	list := make([]utils.FileForProcess, len(files))
+	for i, bf := range files {
+		bf.Num = i + 1
+		bf.To = format.ReplaceExt(bf.To, opts.Format)
+		list[i] = bf
+	}
+
+push:
+	for _, bf := range list {
+
+		select {
+		case <-ctx.Done():
+			break push
+		case jobs <- bf:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if ctx.Err() != nil && report.Processed+report.Skipped < report.Total {
+		report.Canceled = true
+	}
+
+	if deps != nil {
+		if !report.Canceled {
+			deps.keepOnly(list)
+		}
+		if err := deps.save(opts.mode()); err != nil && opts.Logger != nil {
+			opts.Logger.Printf("warning: manifest: %s", err)
+		}
+		report.Dependencies = deps.dependencies()
+	}
+
+	sort.Slice(report.Failures, func(i, j int) bool {
+		return report.Failures[i].File < report.Failures[j].File
+	})
+
+	report.Duration = time.Since(startTime)
+	return report
+}
@@ -0,0 +1,213 @@
+package batch_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/iostrovok/yacs-go/yacs-go/batch"
+	"github.com/iostrovok/yacs-go/yacs-go/format"
+	"github.com/iostrovok/yacs-go/yacs-go/helper"
+	"github.com/iostrovok/yacs-go/yacs-go/loader"
+	"github.com/iostrovok/yacs-go/yacs-go/utils"
+
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func Test(t *testing.T) { TestingT(t) }
+
+type batchTestSuite struct {
+	in, out string
+}
+
+var _ = Suite(&batchTestSuite{})
+
+func (s *batchTestSuite) SetUpTest(c *C) {
+	s.in, s.out = c.MkDir(), c.MkDir()
+
+	s.write(c, "a.json", `{"a": 1}`)
+	s.write(c, "b.yaml", "b: 2\n")
+	s.write(c, "bad.json", `{"a": `)
+	s.write(c, "sub/ref.json", `{"x": {"$ref": "missing.json"}}`)
+}
+
+func (s *batchTestSuite) write(c *C, name, body string) {
+	file := filepath.Join(s.in, name)
+	c.Assert(os.MkdirAll(filepath.Dir(file), 0777), IsNil)
+	c.Assert(ioutil.WriteFile(file, []byte(body), 0666), IsNil)
+}
+
+func (s *batchTestSuite) files(c *C) []utils.FileForProcess {
+	list, err := utils.FindAllFiles(s.in, s.out, "")
+	c.Assert(err, IsNil)
+	return list
+}
+
+func (s *batchTestSuite) Test_Run_CollectsErrors(c *C) {
+	var started int32
+	done := 0
+	report := batch.Run(context.Background(), helper.NewProcessor(), s.files(c), batch.Options{
+		Workers: 2,
+		Format:  format.JSONCompact,
+		Start:   func(utils.FileForProcess) { atomic.AddInt32(&started, 1) },
+		Done:    func(utils.FileForProcess, error) { done++ },
+	})
+
+	c.Assert(report.Total, Equals, 4)
+	c.Assert(report.Processed, Equals, 4)
+	c.Assert(report.Written, Equals, 2)
+	c.Assert(report.Canceled, Equals, false)
+	c.Assert(done, Equals, 4)
+	c.Assert(started, Equals, int32(4))
+	c.Assert(report.Err(), ErrorMatches, "2 of 4 files have failed")
+
+	c.Assert(report.Failures, HasLen, 2)
+	c.Assert(report.Failures[0].File, Equals, filepath.Join(s.in, "bad.json"))
+	c.Assert(report.Failures[0].Stage, Equals, "load")
+	c.Assert(report.Failures[1].File, Equals, filepath.Join(s.in, "sub/ref.json"))
+	c.Assert(report.Failures[1].Stage, Equals, "resolve")
+	c.Assert(report.Failures[1], ErrorMatches, ".*ref.json \\[resolve\\]: .*ref.json:1:8: .*missing.json.*")
+
+	body, err := ioutil.ReadFile(filepath.Join(s.out, "b.json"))
+	c.Assert(err, IsNil)
+	c.Assert(string(body), Equals, "{\"b\":2}\n")
+}
+
+func (s *batchTestSuite) Test_Run_FailFast(c *C) {
+	report := batch.Run(context.Background(), helper.NewProcessor(), s.files(c), batch.Options{FailFast: true})
+
+	c.Assert(report.Canceled, Equals, true)
+	c.Assert(report.Failures, HasLen, 1)
+	c.Assert(report.Processed < report.Total, Equals, true)
+}
+
+func (s *batchTestSuite) Test_Run_Canceled(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	report := batch.Run(ctx, helper.NewProcessor(), s.files(c), batch.Options{Workers: 4})
+	c.Assert(report.Canceled, Equals, true)
+	c.Assert(report.Err(), ErrorMatches, "the batch has been canceled.*")
+}
+
+func (s *batchTestSuite) Test_ProcessFile(c *C) {
+	to := filepath.Join(s.out, "a.yaml")
+	c.Assert(batch.ProcessFile(helper.NewProcessor(), filepath.Join(s.in, "a.json"), to, batch.Options{Format: format.YAML}), IsNil)
+
+	body, err := ioutil.ReadFile(to)
+	c.Assert(err, IsNil)
+	c.Assert(string(body), Equals, "a: 1\n")
+
+	err = batch.ProcessFile(helper.NewProcessor(), filepath.Join(s.in, "a.json"), to, batch.Options{Format: format.TOML + "x"})
+	c.Assert(err, FitsTypeOf, &batch.Failure{})
+	c.Assert(err.(*batch.Failure).Stage, Equals, batch.StageEncode)
+}
+
+func (s *batchTestSuite) Test_Run_Incremental(c *C) {
+	s.write(c, "sub/ref.json", `{"x": {"$ref": "../a.json"}}`)
+
+	for _, check := range []batch.CheckMode{batch.CheckMtime, batch.CheckHash} {
+		out := c.MkDir()
+		list, err := utils.FindAllFiles(s.in, out, "")
+		c.Assert(err, IsNil)
+
+		opts := batch.Options{Manifest: filepath.Join(out, batch.ManifestName), Check: check, Settings: "v1"}
+		run := func() *batch.Report {
+			return batch.Run(context.Background(), helper.NewProcessor(), list, opts)
+		}
+
+		report := run()
+		c.Assert(report.Processed, Equals, 4)
+		c.Assert(report.Written, Equals, 3)
+		// Dependencies of failed files are kept too, so their fixes are found by watching.
+		c.Assert(report.Dependencies, DeepEquals, []string{
+			filepath.Join(s.in, "a.json"),
+			filepath.Join(s.in, "b.yaml"),
+			filepath.Join(s.in, "bad.json"),
+			filepath.Join(s.in, "sub/ref.json"),
+		})
+
+		// Failed files are processed every time.
+		report = run()
+		c.Assert(report.Processed, Equals, 1)
+		c.Assert(report.Skipped, Equals, 3)
+
+		s.write(c, "a.json", `{"a": "`+string(check)+`"}`)
+		report = run()
+		c.Assert(report.Processed, Equals, 3)
+		c.Assert(report.Skipped, Equals, 1)
+
+		body, err := ioutil.ReadFile(filepath.Join(out, "sub/ref.json"))
+		c.Assert(err, IsNil)
+		c.Assert(string(body), Matches, `(?s).*"a": "`+string(check)+`".*`)
+
+		c.Assert(os.Remove(filepath.Join(out, "b.yaml")), IsNil)
+		report = run()
+		c.Assert(report.Processed, Equals, 2)
+
+		opts.Settings = "v2"
+		report = run()
+		c.Assert(report.Processed, Equals, 4)
+		c.Assert(report.Err(), ErrorMatches, "1 of 4 files have failed")
+	}
+}
+
+// editLoader changes the file once after it's loaded, like the user who saves it during processing.
+type editLoader struct {
+	file, body string
+	done       bool
+}
+
+func (l *editLoader) Load(uri string) (*loader.Resource, error) {
+	res, err := loader.FileLoader{}.Load(uri)
+	if err == nil && uri == l.file && !l.done {
+		l.done = true
+		err = ioutil.WriteFile(l.file, []byte(l.body), 0666)
+	}
+	return res, err
+}
+
+func (s *batchTestSuite) Test_Run_Incremental_ChangedDuringProcessing(c *C) {
+	s.write(c, "sub/ref.json", `{"x": {"$ref": "../a.json"}}`)
+
+	for _, check := range []batch.CheckMode{batch.CheckMtime, batch.CheckHash} {
+		out := c.MkDir()
+		list, err := utils.FindAllFiles(s.in, out, "")
+		c.Assert(err, IsNil)
+
+		opts := batch.Options{Manifest: filepath.Join(out, batch.ManifestName), Check: check}
+		c.Assert(batch.Run(context.Background(), helper.NewProcessor(), list, opts).Written, Equals, 3)
+
+		// a.json is saved again while its results are made of the previous version.
+		s.write(c, "a.json", `{"a": 2}`)
+		edit := &editLoader{file: filepath.Join(s.in, "a.json"), body: `{"a": "` + string(check) + `"}`}
+		report := batch.Run(context.Background(), helper.NewProcessor(helper.WithLoader("file", edit)), list, opts)
+		c.Assert(report.Processed, Equals, 3)
+
+		body, err := ioutil.ReadFile(filepath.Join(out, "a.json"))
+		c.Assert(err, IsNil)
+		c.Assert(string(body), Matches, `(?s).*"a": 2.*`)
+
+		// The stale result is made again.
+		report = batch.Run(context.Background(), helper.NewProcessor(), list, opts)
+		c.Assert(report.Processed, Equals, 2)
+		c.Assert(report.Skipped, Equals, 2)
+
+		body, err = ioutil.ReadFile(filepath.Join(out, "a.json"))
+		c.Assert(err, IsNil)
+		c.Assert(string(body), Matches, `(?s).*"a": "`+string(check)+`".*`)
+	}
+}
+
+func (s *batchTestSuite) Test_ParseCheckMode(c *C) {
+	m, err := batch.ParseCheckMode("HASH")
+	c.Assert(err, IsNil)
+	c.Assert(m, Equals, batch.CheckHash)
+
+	_, err = batch.ParseCheckMode("size")
+	c.Assert(err, ErrorMatches, "unknown check mode 'size'.*")
+}
@@ -0,0 +1,323 @@
+package batch
+
+/*
+
+Manifest keeps dependencies of results for incremental builds.
+
+Every result has the list of files which have been loaded to make it: the source,
+"$ref", "@parent" and "@schemas" documents. The result is made again only if
+it's missing or any of its dependencies has been changed. The dependency which is changed
+while the result is made leaves the result out of the manifest, so it's made again next time.
+
+Example usage:
+
+	report := batch.Run(ctx, p, files, batch.Options{
+		Manifest: filepath.Join(outDir, batch.ManifestName),
+		Check:    batch.CheckHash,
+	})
+
+	fmt.Println(report.Skipped, "files are up to date")
+
+Remote dependencies can't be checked, so their results are made every time.
+
+*/
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iostrovok/yacs-go/yacs-go/utils"
+)
+
+// ManifestName is the default name of the manifest in the out dir.
+const ManifestName = ".yacs-manifest.json"
+
+// manifestVersion is changed when the layout of the manifest is changed.
+const manifestVersion = 1
+
+// CheckMode tells how changes of dependencies are found.
+type CheckMode string
+
+const (
+	// CheckMtime compares time of modification and size of files. It's the default mode.
+	CheckMtime CheckMode = "mtime"
+	// CheckHash compares SHA-256 of content of files.
+	CheckHash CheckMode = "hash"
+)
+
+// ParseCheckMode checks the name of the mode.
+func ParseCheckMode(name string) (CheckMode, error) {
+	switch m := CheckMode(strings.ToLower(name)); m {
+	case CheckMtime, CheckHash:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown check mode '%s', it may be %s or %s", name, CheckMtime, CheckHash)
+}
+
+type manifestEntry struct {
+	Source string `json:"source"`
+	// Dependencies are fingerprints of loaded files by their URIs.
+	Dependencies map[string]string `json:"dependencies"`
+	// Failed results are made every time, their dependencies are kept to watch them.
+	Failed bool `json:"failed,omitempty"`
+}
+
+type manifest struct {
+	Version  int       `json:"version"`
+	Check    CheckMode `json:"check"`
+	Settings string    `json:"settings"`
+	// Outputs are entries by result files.
+	Outputs map[string]*manifestEntry `json:"outputs"`
+
+	file string
+	mu   sync.Mutex
+}
+
+// loadManifest reads the manifest. Missing, broken or not matching file gives the empty manifest,
+// so all files are made again.
+func loadManifest(file string, check CheckMode, settings string) *manifest {
+
+	empty := &manifest{
+		Version:  manifestVersion,
+		Check:    check,
+		Settings: settings,
+		Outputs:  map[string]*manifestEntry{},
+		file:     file,
+	}
+
+	body, err := ioutil.ReadFile(file)
+	if err != nil {
+		return empty
+	}
+
+	m := &manifest{}
+	if err := json.Unmarshal(body, m); err != nil ||
+		m.Version != manifestVersion || m.Check != check || m.Settings != settings || m.Outputs == nil {
+		return empty
+	}
+
+	m.file = file
+	return m
+}
+
+// upToDate checks that the result exists and no dependency has been changed.
+func (m *manifest) upToDate(bf utils.FileForProcess) bool {
+
+	m.mu.Lock()
+	e, find := m.Outputs[bf.To]
+	m.mu.Unlock()
+
+	if !find || e.Failed || e.Source != bf.From || len(e.Dependencies) == 0 {
+		return false
+	}
+
+	if _, err := os.Stat(bf.To); err != nil {
+		return false
+	}
+
+	for uri, fp := range e.Dependencies {
+		if now, ok := Fingerprint(uri, m.Check); !ok || now != fp {
+			return false
+		}
+	}
+
+	return true
+}
+
+// snapshot is the state of dependencies before processing.
+type snapshot struct {
+	at time.Time
+	// fingerprints are of the source and of the dependencies of the previous build by URIs.
+	fingerprints map[string]string
+}
+
+// snapshot takes fingerprints of the files which the result is expected to depend on.
+func (m *manifest) snapshot(bf utils.FileForProcess) *snapshot {
+
+	s := &snapshot{at: time.Now(), fingerprints: map[string]string{}}
+
+	uris := []string{bf.From}
+	m.mu.Lock()
+	if e, find := m.Outputs[bf.To]; find {
+		for uri := range e.Dependencies {
+			uris = append(uris, uri)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, uri := range uris {
+		if fp, ok := Fingerprint(uri, m.Check); ok {
+			s.fingerprints[uri] = fp
+		}
+	}
+	return s
+}
+
+// unchanged checks that the dependency hasn't been changed during processing.
+// New dependencies have no fingerprints before, they are checked by the time of modification.
+func (s *snapshot) unchanged(uri, fp string) bool {
+	if before, find := s.fingerprints[uri]; find {
+		return before == fp
+	}
+
+	file, _ := localFile(uri)
+	info, err := os.Stat(file)
+	return err == nil && info.ModTime().Before(s.at)
+}
+
+// set saves dependencies of the result. Result without dependencies is removed from the manifest.
+// So is the result if any dependency has been changed since the snapshot, it may be made of the old version.
+func (m *manifest) set(bf utils.FileForProcess, deps []string, before *snapshot) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.Outputs, bf.To)
+
+	e := &manifestEntry{Source: bf.From, Dependencies: map[string]string{}}
+	for _, uri := range deps {
+		fp, ok := Fingerprint(uri, m.Check)
+		if !ok || !before.unchanged(uri, fp) {
+			// The result has to be made again.
+			return
+		}
+		e.Dependencies[uri] = fp
+	}
+
+	if len(e.Dependencies) > 0 {
+		m.Outputs[bf.To] = e
+	}
+}
+
+// fail marks the result as failed, so it's made next time. Requested files and the files of
+// the previous build are kept, so watching sees fixes of them. Missing files have no fingerprints.
+func (m *manifest) fail(bf utils.FileForProcess, deps []string) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e := &manifestEntry{Source: bf.From, Dependencies: map[string]string{}, Failed: true}
+	if old, find := m.Outputs[bf.To]; find && old.Source == bf.From {
+		for uri := range old.Dependencies {
+			e.Dependencies[uri] = ""
+		}
+	}
+
+	for _, uri := range append(deps, bf.From) {
+		e.Dependencies[uri] = ""
+	}
+	for uri := range e.Dependencies {
+		e.Dependencies[uri], _ = Fingerprint(uri, m.Check)
+	}
+
+	m.Outputs[bf.To] = e
+}
+
+// keepOnly removes results which are not made from the files any more.
+func (m *manifest) keepOnly(files []utils.FileForProcess) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	list := map[string]bool{}
+	for _, bf := range files {
+		list[bf.To] = true
+	}
+
+	for to := range m.Outputs {
+		if !list[to] {
+			delete(m.Outputs, to)
+		}
+	}
+}
+
+// dependencies returns all local files of all results.
+func (m *manifest) dependencies() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	list := map[string]bool{}
+	for _, e := range m.Outputs {
+		for uri := range e.Dependencies {
+			if file, ok := localFile(uri); ok {
+				list[file] = true
+			}
+		}
+	}
+
+	out := make([]string, 0, len(list))
+	for file := range list {
+		out = append(out, file)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// save writes the manifest via temporary file, so the manifest is never half written.
+func (m *manifest) save(mode os.FileMode) error {
+	m.mu.Lock()
+	body, err := json.MarshalIndent(m, "", "    ")
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp := m.file + ".tmp"
+	if err := utils.SaveFile(tmp, append(body, '\n'), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.file)
+}
+
+// localFile returns the path of the local file by URI, ok is false for remote URIs.
+func localFile(uri string) (string, bool) {
+
+	if utils.IsRemoteURI(uri) {
+		return "", false
+	}
+
+	if u, err := url.Parse(uri); err == nil && u.Scheme == "file" {
+		return filepath.FromSlash(u.Path), true
+	}
+
+	return uri, true
+}
+
+// Fingerprint returns the state of the local file by URI, ok is false if it's unknown or the file is remote.
+func Fingerprint(uri string, check CheckMode) (string, bool) {
+
+	file, ok := localFile(uri)
+	if !ok {
+		return "", false
+	}
+
+	if check == CheckHash {
+		f, err := os.Open(file)
+		if err != nil {
+			return "", false
+		}
+		defer f.Close()
+
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return "", false
+		}
+		return "sha256:" + hex.EncodeToString(h.Sum(nil)), true
+	}
+
+	info, err := os.Stat(file)
+	if err != nil || info.IsDir() {
+		return "", false
+	}
+	return fmt.Sprintf("%d:%d", info.ModTime().UnixNano(), info.Size()), true
+}
@@ -0,0 +1,211 @@
+package cache
+
+/*
+
+Caches of loaded documents. Every cache is safe for concurrent use.
+Documents are copied on Add and on Get, so callers may change them.
+
+Example usage:
+
+	c := cache.NewLRU(100, 10*1024*1024)
+	c.Add("/configs/app.json", cache.Entry{Doc: doc, Version: "v1", Size: 1024})
+
+	e, find := c.Get("/configs/app.json", func(e cache.Entry) bool {
+		return e.Version == "v1"
+	})
+
+*/
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/iostrovok/yacs-go/yacs-go/format"
+	"github.com/iostrovok/yacs-go/yacs-go/utils"
+)
+
+// Entry is a cached document.
+type Entry struct {
+	Doc interface{}
+	// Version is mtime and size of file or ETag of URL. Empty string means unknown version.
+	Version string
+	// Size is the size of raw document in bytes.
+	Size int64
+	// Positions are places of values in the raw document, they are not copied.
+	Positions format.Positions
+}
+
+// Stats is hit/miss statistics of cache.
+type Stats struct {
+	Hits      int64
+	Misses    int64
+	Stale     int64
+	Evictions int64
+	Entries   int
+	Bytes     int64
+}
+
+// Add sums counters of two stats.
+func (s Stats) Add(o Stats) Stats {
+	return Stats{
+		Hits:      s.Hits + o.Hits,
+		Misses:    s.Misses + o.Misses,
+		Stale:     s.Stale + o.Stale,
+		Evictions: s.Evictions + o.Evictions,
+		Entries:   s.Entries + o.Entries,
+		Bytes:     s.Bytes + o.Bytes,
+	}
+}
+
+// Cache stores documents by absolute URI.
+type Cache interface {
+	// Get returns entry by key. If valid isn't nil and returns false,
+	// the entry is stale: it's removed and Get reports a miss.
+	Get(key string, valid func(Entry) bool) (Entry, bool)
+	Add(key string, e Entry)
+	Reset()
+	Stats() Stats
+}
+
+type noneCache struct {
+	mu    sync.Mutex
+	stats Stats
+}
+
+// NewNone returns cache which stores nothing.
+func NewNone() Cache {
+	return &noneCache{}
+}
+
+func (c *noneCache) Get(key string, valid func(Entry) bool) (Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.Misses++
+	return Entry{}, false
+}
+
+func (c *noneCache) Add(key string, e Entry) {}
+
+func (c *noneCache) Reset() {}
+
+func (c *noneCache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// lruCache is used for unbounded map cache too.
+type lruCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	maxBytes   int64
+	order      *list.List
+	items      map[string]*list.Element
+	stats      Stats
+}
+
+type lruItem struct {
+	key   string
+	entry Entry
+}
+
+// NewMap returns unbounded cache.
+func NewMap() Cache {
+	return NewLRU(0, 0)
+}
+
+// NewLRU returns cache which drops least recently used entries
+// when it has more than maxEntries or maxBytes. Zero means no limit.
+func NewLRU(maxEntries int, maxBytes int64) Cache {
+	return &lruCache{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		order:      list.New(),
+		items:      map[string]*list.Element{},
+	}
+}
+
+func (c *lruCache) Get(key string, valid func(Entry) bool) (Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, find := c.items[key]
+	if !find {
+		c.stats.Misses++
+		return Entry{}, false
+	}
+
+	item := el.Value.(*lruItem)
+	if valid != nil && !valid(item.entry) {
+		c.remove(el)
+		c.stats.Stale++
+		c.stats.Misses++
+		return Entry{}, false
+	}
+
+	doc, err := utils.DeepCopy(item.entry.Doc)
+	if err != nil {
+		c.stats.Misses++
+		return Entry{}, false
+	}
+
+	c.order.MoveToFront(el)
+	c.stats.Hits++
+
+	out := item.entry
+	out.Doc = doc
+	return out, true
+}
+
+func (c *lruCache) Add(key string, e Entry) {
+
+	doc, err := utils.DeepCopy(e.Doc)
+	if err != nil {
+		return
+	}
+	e.Doc = doc
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, find := c.items[key]; find {
+		c.remove(el)
+	}
+
+	c.items[key] = c.order.PushFront(&lruItem{key: key, entry: e})
+	c.stats.Entries++
+	c.stats.Bytes += e.Size
+
+	for c.order.Len() > 1 && c.overflow() {
+		c.remove(c.order.Back())
+		c.stats.Evictions++
+	}
+}
+
+func (c *lruCache) overflow() bool {
+	return (c.maxEntries > 0 && c.stats.Entries > c.maxEntries) ||
+		(c.maxBytes > 0 && c.stats.Bytes > c.maxBytes)
+}
+
+func (c *lruCache) remove(el *list.Element) {
+	item := c.order.Remove(el).(*lruItem)
+	delete(c.items, item.key)
+	c.stats.Entries--
+	c.stats.Bytes -= item.entry.Size
+}
+
+func (c *lruCache) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.order.Init()
+	c.items = map[string]*list.Element{}
+	c.stats.Entries = 0
+	c.stats.Bytes = 0
+}
+
+func (c *lruCache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func Test(t *testing.T) { TestingT(t) }
+
+type cacheTestSuite struct{}
+
+var _ = Suite(&cacheTestSuite{})
+
+func doc(v string) map[string]interface{} {
+	return map[string]interface{}{"v": v}
+}
+
+func (s *cacheTestSuite) Test_None(c *C) {
+	ch := NewNone()
+	ch.Add("a", Entry{Doc: doc("a")})
+
+	_, find := ch.Get("a", nil)
+	c.Assert(find, Equals, false)
+	c.Assert(ch.Stats(), DeepEquals, Stats{Misses: 1})
+}
+
+func (s *cacheTestSuite) Test_Map_Copy(c *C) {
+	ch := NewMap()
+	d := doc("a")
+	ch.Add("a", Entry{Doc: d, Version: "1"})
+	d["v"] = "changed"
+
+	e, find := ch.Get("a", nil)
+	c.Assert(find, Equals, true)
+	c.Assert(e.Doc, DeepEquals, doc("a"))
+	c.Assert(e.Version, Equals, "1")
+
+	e.Doc.(map[string]interface{})["v"] = "changed"
+	e, _ = ch.Get("a", nil)
+	c.Assert(e.Doc, DeepEquals, doc("a"))
+
+	_, find = ch.Get("b", nil)
+	c.Assert(find, Equals, false)
+	c.Assert(ch.Stats(), DeepEquals, Stats{Hits: 2, Misses: 1, Entries: 1})
+}
+
+func (s *cacheTestSuite) Test_Stale(c *C) {
+	ch := NewMap()
+	ch.Add("a", Entry{Doc: doc("a"), Version: "1"})
+
+	_, find := ch.Get("a", func(e Entry) bool { return e.Version == "2" })
+	c.Assert(find, Equals, false)
+
+	_, find = ch.Get("a", nil)
+	c.Assert(find, Equals, false)
+	c.Assert(ch.Stats(), DeepEquals, Stats{Misses: 2, Stale: 1})
+}
+
+func (s *cacheTestSuite) Test_LRU_Entries(c *C) {
+	ch := NewLRU(2, 0)
+	ch.Add("a", Entry{Doc: doc("a")})
+	ch.Add("b", Entry{Doc: doc("b")})
+	ch.Get("a", nil)
+	ch.Add("c", Entry{Doc: doc("c")})
+
+	_, find := ch.Get("b", nil)
+	c.Assert(find, Equals, false)
+	_, find = ch.Get("a", nil)
+	c.Assert(find, Equals, true)
+	_, find = ch.Get("c", nil)
+	c.Assert(find, Equals, true)
+	c.Assert(ch.Stats().Evictions, Equals, int64(1))
+	c.Assert(ch.Stats().Entries, Equals, 2)
+}
+
+func (s *cacheTestSuite) Test_LRU_Bytes(c *C) {
+	ch := NewLRU(0, 100)
+	ch.Add("a", Entry{Doc: doc("a"), Size: 60})
+	ch.Add("b", Entry{Doc: doc("b"), Size: 60})
+
+	_, find := ch.Get("a", nil)
+	c.Assert(find, Equals, false)
+	c.Assert(ch.Stats().Bytes, Equals, int64(60))
+
+	ch.Reset()
+	c.Assert(ch.Stats().Entries, Equals, 0)
+	c.Assert(ch.Stats().Bytes, Equals, int64(0))
+}
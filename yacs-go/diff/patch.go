@@ -0,0 +1,156 @@
+package diff
+
+/*
+
+Structured differences and JSON Patch: https://tools.ietf.org/html/rfc6902
+
+Example usage:
+
+	changes := diff.Changes(a, b)
+	patch, err := json.Marshal(diff.Patch(changes))
+
+The patch turns A into B.
+
+*/
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/iostrovok/yacs-go/yacs-go/jsonpointer"
+)
+
+// Op is a kind of change.
+type Op string
+
+const (
+	// OpAdd - the value exists only in B.
+	OpAdd Op = "add"
+	// OpRemove - the value exists only in A.
+	OpRemove Op = "remove"
+	// OpReplace - the values are different.
+	OpReplace Op = "replace"
+)
+
+// Change is a single difference between documents A and B.
+type Change struct {
+	Op Op
+	// Path is JSON pointer of the value.
+	Path string
+	// OldValue is the value in A, it's nil for OpAdd.
+	OldValue interface{}
+	// NewValue is the value in B, it's nil for OpRemove.
+	NewValue interface{}
+}
+
+// PatchOperation is one operation of JSON Patch.
+type PatchOperation struct {
+	Op    Op
+	Path  string
+	Value interface{}
+}
+
+// MarshalJSON writes "value" for all operations except "remove", null values are kept.
+func (o PatchOperation) MarshalJSON() ([]byte, error) {
+	if o.Op == OpRemove {
+		return json.Marshal(struct {
+			Op   Op     `json:"op"`
+			Path string `json:"path"`
+		}{o.Op, o.Path})
+	}
+
+	return json.Marshal(struct {
+		Op    Op          `json:"op"`
+		Path  string      `json:"path"`
+		Value interface{} `json:"value"`
+	}{o.Op, o.Path, o.Value})
+}
+
+// Changes returns differences between a and b in the order
+// in which they may be applied: removed array items go from the end.
+func Changes(a, b interface{}) []Change {
+	return changes(a, b, jsonpointer.Pointer{}, []Change{})
+}
+
+// Patch converts changes to JSON Patch which turns A into B.
+func Patch(changes []Change) []PatchOperation {
+	out := make([]PatchOperation, 0, len(changes))
+	for _, c := range changes {
+		op := PatchOperation{Op: c.Op, Path: c.Path}
+		if c.Op != OpRemove {
+			op.Value = c.NewValue
+		}
+		out = append(out, op)
+	}
+	return out
+}
+
+func changes(a, b interface{}, path jsonpointer.Pointer, out []Change) []Change {
+
+	switch am := a.(type) {
+	case map[string]interface{}:
+		bm, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+
+		keys := make([]string, 0, len(am)+len(bm))
+		for k := range am {
+			keys = append(keys, k)
+		}
+		for k := range bm {
+			if _, find := am[k]; !find {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			p := path.Append(k)
+			av, inA := am[k]
+			bv, inB := bm[k]
+
+			switch {
+			case !inB:
+				out = append(out, Change{Op: OpRemove, Path: p.String(), OldValue: av})
+			case !inA:
+				out = append(out, Change{Op: OpAdd, Path: p.String(), NewValue: bv})
+			default:
+				out = changes(av, bv, p, out)
+			}
+		}
+		return out
+
+	case []interface{}:
+		bl, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+
+		common := len(am)
+		if len(bl) < common {
+			common = len(bl)
+		}
+
+		for i := 0; i < common; i++ {
+			out = changes(am[i], bl[i], path.Append(strconv.Itoa(i)), out)
+		}
+
+		for i := len(am) - 1; i >= common; i-- {
+			out = append(out, Change{Op: OpRemove, Path: path.Append(strconv.Itoa(i)).String(), OldValue: am[i]})
+		}
+
+		for i := common; i < len(bl); i++ {
+			out = append(out, Change{Op: OpAdd, Path: path.Append(strconv.Itoa(i)).String(), NewValue: bl[i]})
+		}
+		return out
+	}
+
+	if reflect.DeepEqual(a, b) {
+		return out
+	}
+
+	return append(out, Change{Op: OpReplace, Path: path.String(), OldValue: a, NewValue: b})
+}
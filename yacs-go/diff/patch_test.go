@@ -0,0 +1,82 @@
+package diff
+
+import (
+	"encoding/json"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/iostrovok/yacs-go/yacs-go/jsonpointer"
+)
+
+type patchTestSuite struct{}
+
+var _ = Suite(&patchTestSuite{})
+
+// apply is enough for patches which are made by Patch(): "add" appends to arrays.
+func apply(c *C, doc interface{}, patch []PatchOperation) interface{} {
+	var err error
+	for _, op := range patch {
+		p, perr := jsonpointer.Parse(op.Path)
+		c.Assert(perr, IsNil)
+
+		if op.Op == OpRemove {
+			doc, err = jsonpointer.Delete(doc, p)
+		} else {
+			doc, err = jsonpointer.Set(doc, p, op.Value)
+		}
+		c.Assert(err, IsNil, Commentf("%+v", op))
+	}
+	return doc
+}
+
+func decode(c *C, s string) interface{} {
+	var doc interface{}
+	c.Assert(json.Unmarshal([]byte(s), &doc), IsNil)
+	return doc
+}
+
+func (s *patchTestSuite) Test_Changes(c *C) {
+	a := decode(c, `{"a": {"b": 1, "c": "x"}, "d/e": true, "list": [1, 2, 3], "same": [1], "t": 1}`)
+	b := decode(c, `{"a": {"b": 2, "n": null}, "d/e": true, "list": [1], "same": [1], "t": "1"}`)
+
+	c.Assert(Changes(a, b), DeepEquals, []Change{
+		{Op: OpReplace, Path: "/a/b", OldValue: float64(1), NewValue: float64(2)},
+		{Op: OpRemove, Path: "/a/c", OldValue: "x"},
+		{Op: OpAdd, Path: "/a/n", NewValue: nil},
+		{Op: OpRemove, Path: "/list/2", OldValue: float64(3)},
+		{Op: OpRemove, Path: "/list/1", OldValue: float64(2)},
+		{Op: OpReplace, Path: "/t", OldValue: float64(1), NewValue: "1"},
+	})
+}
+
+func (s *patchTestSuite) Test_Patch_JSON(c *C) {
+	a := decode(c, `{"a~b": [1], "gone": 1, "n": 1}`)
+	b := decode(c, `{"a~b": [1, {"x": 1}], "n": null}`)
+
+	out, err := json.Marshal(Patch(Changes(a, b)))
+	c.Assert(err, IsNil)
+	c.Assert(string(out), Equals, `[{"op":"add","path":"/a~0b/1","value":{"x":1}},{"op":"remove","path":"/gone"},{"op":"replace","path":"/n","value":null}]`)
+}
+
+func (s *patchTestSuite) Test_Patch_Apply(c *C) {
+	a := loadFile(c, "./my.json")
+	b := loadFile(c, "./my.json")
+
+	c.Assert(Changes(a, b), HasLen, 0)
+
+	bm := b.(map[string]interface{})
+	for k := range bm {
+		bm[k] = []interface{}{"changed", map[string]interface{}{"k": k}}
+		break
+	}
+	bm["new/key"] = map[string]interface{}{"list": []interface{}{1.0, 2.0}}
+
+	patch := Patch(Changes(a, b))
+	c.Assert(len(patch) > 0, Equals, true)
+	c.Assert(apply(c, a, patch), DeepEquals, b)
+}
+
+func (s *patchTestSuite) Test_Patch_Root(c *C) {
+	patch := Patch(Changes("a", []interface{}{"b"}))
+	c.Assert(patch, DeepEquals, []PatchOperation{{Op: OpReplace, Path: "", Value: []interface{}{"b"}}})
+}
@@ -0,0 +1,255 @@
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// JSONCompact is JSON without indents.
+	JSONCompact Format = "json-compact"
+	// TOML format. The document must be an object.
+	TOML Format = "toml"
+	// Env is dotenv format: KEY="value" with flattened upper case keys like DATABASE_HOST.
+	Env Format = "env"
+	// Properties is Java .properties format with flattened keys like database.host.
+	Properties Format = "properties"
+)
+
+// Output is the list of formats which may be used by Encode.
+var Output = []Format{JSON, JSONCompact, YAML, TOML, Env, Properties}
+
+var outputExtensions = map[Format]string{
+	JSON:        ".json",
+	JSONCompact: ".json",
+	YAML:        ".yaml",
+	TOML:        ".toml",
+	Env:         ".env",
+	Properties:  ".properties",
+}
+
+var notEnvChars = regexp.MustCompile(`[^A-Z0-9_]+`)
+
+// Parse checks the name of output format.
+func Parse(name string) (Format, error) {
+	f := Format(strings.ToLower(name))
+	if _, find := outputExtensions[f]; !find {
+		return "", fmt.Errorf("unknown output format '%s', it may be %s", name, Output)
+	}
+	return f, nil
+}
+
+// Ext returns file extension for output format.
+func (f Format) Ext() string {
+	return outputExtensions[f]
+}
+
+// ReplaceExt changes extension of input document (.json, .yaml, .yml) to the extension of format.
+// Other file names are not changed.
+func ReplaceExt(file string, f Format) string {
+	ext := filepath.Ext(file)
+	if _, find := extensions[strings.ToLower(ext)]; !find || f.Ext() == "" {
+		return file
+	}
+	return strings.TrimSuffix(file, ext) + f.Ext()
+}
+
+// Encode converts document to format. The result ends with new line.
+func Encode(f Format, doc interface{}) ([]byte, error) {
+
+	switch f {
+	case JSON:
+		out, err := json.MarshalIndent(doc, "", "    ")
+		return append(out, '\n'), err
+
+	case JSONCompact:
+		out, err := json.Marshal(doc)
+		return append(out, '\n'), err
+
+	case YAML:
+		var b bytes.Buffer
+		enc := yaml.NewEncoder(&b)
+		enc.SetIndent(2)
+		if err := enc.Encode(doc); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), enc.Close()
+
+	case TOML:
+		if _, ok := doc.(map[string]interface{}); !ok {
+			return nil, fmt.Errorf("toml: the document must be an object")
+		}
+		var b bytes.Buffer
+		err := toml.NewEncoder(&b).Encode(integers(doc))
+		return b.Bytes(), err
+
+	case Env:
+		return encodeFlat(doc, "_", envLine)
+
+	case Properties:
+		return encodeFlat(doc, ".", propertiesLine)
+	}
+
+	return nil, fmt.Errorf("unknown format '%s'", f)
+}
+
+// integers converts whole float64 to int64, so TOML has 8080 instead of 8080.0.
+func integers(node interface{}) interface{} {
+	switch n := node.(type) {
+	case float64:
+		if n == math.Trunc(n) && math.Abs(n) < 1<<53 {
+			return int64(n)
+		}
+	case map[string]interface{}:
+		out := map[string]interface{}{}
+		for k, v := range n {
+			out[k] = integers(v)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(n))
+		for i, v := range n {
+			out[i] = integers(v)
+		}
+		return out
+	}
+	return node
+}
+
+type flatValue struct {
+	key   string
+	value interface{}
+}
+
+// flatten returns scalar values with keys joined by sep.
+func flatten(node interface{}, prefix, sep string, out []flatValue) []flatValue {
+
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + sep + key
+	}
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for k, v := range n {
+			out = flatten(v, join(k), sep, out)
+		}
+		return out
+
+	case []interface{}:
+		for i, v := range n {
+			out = flatten(v, join(strconv.Itoa(i)), sep, out)
+		}
+		return out
+	}
+
+	return append(out, flatValue{key: prefix, value: node})
+}
+
+func encodeFlat(doc interface{}, sep string, line func(key string, value interface{}) (string, error)) ([]byte, error) {
+
+	values := flatten(doc, "", sep, nil)
+	sort.Slice(values, func(i, j int) bool { return values[i].key < values[j].key })
+
+	var b bytes.Buffer
+	for _, v := range values {
+		l, err := line(v.key, v.value)
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString(l)
+		b.WriteString("\n")
+	}
+
+	return b.Bytes(), nil
+}
+
+func scalarString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	}
+	return "", fmt.Errorf("unsupported value %v (%T)", value, value)
+}
+
+func envLine(key string, value interface{}) (string, error) {
+
+	name := notEnvChars.ReplaceAllString(strings.ToUpper(key), "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+
+	str, err := scalarString(value)
+	if err != nil {
+		return "", fmt.Errorf("env: %s: %s", key, err)
+	}
+
+	if _, ok := value.(string); ok {
+		str = strconv.Quote(str)
+	}
+
+	return name + "=" + str, nil
+}
+
+func propertiesLine(key string, value interface{}) (string, error) {
+
+	str, err := scalarString(value)
+	if err != nil {
+		return "", fmt.Errorf("properties: %s: %s", key, err)
+	}
+
+	return propertiesEscape(key, true) + "=" + propertiesEscape(str, false), nil
+}
+
+// propertiesEscape escapes text like java.util.Properties.store does.
+func propertiesEscape(s string, isKey bool) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\f':
+			b.WriteString(`\f`)
+		case r == ' ' && (isKey || i == 0):
+			b.WriteString(`\ `)
+		case r == '=' || r == ':' || r == '#' || r == '!':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			if r > 0xffff {
+				r1, r2 := utf16.EncodeRune(r)
+				b.WriteString(fmt.Sprintf(`\u%04x\u%04x`, r1, r2))
+				continue
+			}
+			b.WriteString(fmt.Sprintf(`\u%04x`, r))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
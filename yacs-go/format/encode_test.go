@@ -0,0 +1,113 @@
+package format
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type encodeTestSuite struct{}
+
+var _ = Suite(&encodeTestSuite{})
+
+func testDoc() map[string]interface{} {
+	return map[string]interface{}{
+		"name": "web app",
+		"database": map[string]interface{}{
+			"host": "localhost",
+			"port": float64(5432),
+		},
+		"servers": []interface{}{
+			map[string]interface{}{"port": float64(80), "ratio": 0.5},
+		},
+		"debug": false,
+		"empty": nil,
+	}
+}
+
+func (s *encodeTestSuite) Test_JSON(c *C) {
+	out, err := Encode(JSONCompact, testDoc())
+	c.Assert(err, IsNil)
+	c.Assert(string(out), Equals, `{"database":{"host":"localhost","port":5432},"debug":false,"empty":null,"name":"web app","servers":[{"port":80,"ratio":0.5}]}`+"\n")
+
+	out, err = Encode(JSON, map[string]interface{}{"a": float64(1)})
+	c.Assert(err, IsNil)
+	c.Assert(string(out), Equals, "{\n    \"a\": 1\n}\n")
+}
+
+func (s *encodeTestSuite) Test_YAML(c *C) {
+	out, err := Encode(YAML, testDoc())
+	c.Assert(err, IsNil)
+
+	back, err := Decode(YAML, out)
+	c.Assert(err, IsNil)
+	c.Assert(back, DeepEquals, testDoc())
+}
+
+func (s *encodeTestSuite) Test_TOML(c *C) {
+	out, err := Encode(TOML, testDoc())
+	c.Assert(err, IsNil)
+	c.Assert(string(out), Equals, `debug = false
+name = "web app"
+
+[database]
+  host = "localhost"
+  port = 5432
+
+[[servers]]
+  port = 80
+  ratio = 0.5
+`)
+
+	_, err = Encode(TOML, []interface{}{})
+	c.Assert(err, ErrorMatches, "toml: the document must be an object")
+}
+
+func (s *encodeTestSuite) Test_Env(c *C) {
+	out, err := Encode(Env, testDoc())
+	c.Assert(err, IsNil)
+	c.Assert(string(out), Equals, `DATABASE_HOST="localhost"
+DATABASE_PORT=5432
+DEBUG=false
+EMPTY=
+NAME="web app"
+SERVERS_0_PORT=80
+SERVERS_0_RATIO=0.5
+`)
+
+	out, err = Encode(Env, map[string]interface{}{"market-id": "a\"b", "1st": "x"})
+	c.Assert(err, IsNil)
+	c.Assert(string(out), Equals, "_1ST=\"x\"\nMARKET_ID=\"a\\\"b\"\n")
+}
+
+func (s *encodeTestSuite) Test_Properties(c *C) {
+	out, err := Encode(Properties, testDoc())
+	c.Assert(err, IsNil)
+	c.Assert(string(out), Equals, `database.host=localhost
+database.port=5432
+debug=false
+empty=
+name=web app
+servers.0.port=80
+servers.0.ratio=0.5
+`)
+
+	out, err = Encode(Properties, map[string]interface{}{"a key": " x=y\nü😀"})
+	c.Assert(err, IsNil)
+	c.Assert(string(out), Equals, `a\ key=\ x\=y\n\u00fc\ud83d\ude00`+"\n")
+}
+
+func (s *encodeTestSuite) Test_Parse(c *C) {
+	f, err := Parse("YAML")
+	c.Assert(err, IsNil)
+	c.Assert(f, Equals, YAML)
+
+	_, err = Parse("xml")
+	c.Assert(err, ErrorMatches, "unknown output format 'xml'.*")
+}
+
+func (s *encodeTestSuite) Test_ReplaceExt(c *C) {
+	c.Assert(ReplaceExt("/out/a.yaml", JSON), Equals, "/out/a.json")
+	c.Assert(ReplaceExt("/out/a.json", Properties), Equals, "/out/a.properties")
+	c.Assert(ReplaceExt("/out/a.YML", TOML), Equals, "/out/a.toml")
+	c.Assert(ReplaceExt("/out/a.cfg", YAML), Equals, "/out/a.cfg")
+	c.Assert(ReplaceExt("/out/a", Env), Equals, "/out/a")
+}
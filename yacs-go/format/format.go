@@ -0,0 +1,154 @@
+package format
+
+/*
+
+Formats of config files. JSON and YAML documents are decoded to the same
+tree of map[string]interface{}, []interface{} and scalars like encoding/json makes,
+so they can be mixed freely: YAML file may be a parent of JSON file and so on.
+
+Example usage:
+
+	f := format.Detect("app.yaml", "", body)
+	doc, err := format.Decode(f, body)
+
+*/
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"path"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Format is a name of format of documents.
+type Format string
+
+const (
+	// JSON is the default format.
+	JSON Format = "json"
+	// YAML format.
+	YAML Format = "yaml"
+)
+
+var extensions = map[string]Format{
+	".json": JSON,
+	".yaml": YAML,
+	".yml":  YAML,
+}
+
+var contentTypes = map[string]Format{
+	"application/json":   JSON,
+	"text/json":          JSON,
+	"application/yaml":   YAML,
+	"application/x-yaml": YAML,
+	"text/yaml":          YAML,
+	"text/x-yaml":        YAML,
+}
+
+// Detect returns format by extension of uri, by content type or by content.
+// Content which doesn't start with '{' or '[' is YAML.
+func Detect(uri, contentType string, body []byte) Format {
+
+	u := uri
+	if i := strings.IndexAny(u, "?#"); i >= 0 {
+		u = u[:i]
+	}
+
+	if f, find := extensions[strings.ToLower(path.Ext(u))]; find {
+		return f
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		if f, find := contentTypes[mediaType]; find {
+			return f
+		}
+	}
+
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] != '{' && trimmed[0] != '[' {
+		return YAML
+	}
+
+	return JSON
+}
+
+// Decode parses document. Errors of syntax are *SyntaxError with the place of the problem.
+func Decode(f Format, body []byte) (interface{}, error) {
+
+	var doc interface{}
+
+	switch f {
+	case JSON:
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return nil, syntaxError(body, err)
+		}
+		return doc, nil
+
+	case YAML:
+		if err := yaml.Unmarshal(body, &doc); err != nil {
+			return nil, syntaxError(body, err)
+		}
+		return normalize(doc)
+	}
+
+	return nil, fmt.Errorf("unknown format '%s'", f)
+}
+
+// normalize converts YAML types to the types of encoding/json.
+func normalize(node interface{}) (interface{}, error) {
+
+	var err error
+
+	switch n := node.(type) {
+	case nil, bool, string, float64:
+		return n, nil
+
+	case int:
+		return float64(n), nil
+
+	case int64:
+		return float64(n), nil
+
+	case uint64:
+		return float64(n), nil
+
+	case time.Time:
+		return n.Format(time.RFC3339Nano), nil
+
+	case []byte:
+		return base64.StdEncoding.EncodeToString(n), nil
+
+	case map[string]interface{}:
+		for k, v := range n {
+			if n[k], err = normalize(v); err != nil {
+				return nil, err
+			}
+		}
+		return n, nil
+
+	case map[interface{}]interface{}:
+		out := map[string]interface{}{}
+		for k, v := range n {
+			if out[fmt.Sprint(k)], err = normalize(v); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+
+	case []interface{}:
+		for i, v := range n {
+			if n[i], err = normalize(v); err != nil {
+				return nil, err
+			}
+		}
+		return n, nil
+	}
+
+	return nil, fmt.Errorf("unsupported YAML value %v (%T)", node, node)
+}
@@ -0,0 +1,68 @@
+package format
+
+import (
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func Test(t *testing.T) { TestingT(t) }
+
+type formatTestSuite struct{}
+
+var _ = Suite(&formatTestSuite{})
+
+func (s *formatTestSuite) Test_Detect(c *C) {
+	c.Assert(Detect("a.json", "", nil), Equals, JSON)
+	c.Assert(Detect("a.YAML", "", nil), Equals, YAML)
+	c.Assert(Detect("http://host/a.yml?v=1#/x", "application/json", nil), Equals, YAML)
+	c.Assert(Detect("http://host/config", "application/x-yaml; charset=utf-8", nil), Equals, YAML)
+	c.Assert(Detect("http://host/config", "application/json", []byte("a: b")), Equals, JSON)
+	c.Assert(Detect("", "", []byte(" \n{\"a\": 1}")), Equals, JSON)
+	c.Assert(Detect("", "", []byte("[1]")), Equals, JSON)
+	c.Assert(Detect("", "", []byte("a: 1")), Equals, YAML)
+	c.Assert(Detect("", "text/plain", nil), Equals, JSON)
+}
+
+func (s *formatTestSuite) Test_Decode_YAML(c *C) {
+	doc, err := Decode(YAML, []byte(`
+name: web
+port: 8080
+ratio: 0.5
+enabled: true
+nothing: null
+created: 2019-02-26T10:00:00Z
+"@parent":
+  $ref: parent.json
+list:
+  - 1
+  - two
+numbers:
+  1: one
+`))
+	c.Assert(err, IsNil)
+	c.Assert(doc, DeepEquals, map[string]interface{}{
+		"name":    "web",
+		"port":    float64(8080),
+		"ratio":   0.5,
+		"enabled": true,
+		"nothing": nil,
+		"created": "2019-02-26T10:00:00Z",
+		"@parent": map[string]interface{}{"$ref": "parent.json"},
+		"list":    []interface{}{float64(1), "two"},
+		"numbers": map[string]interface{}{"1": "one"},
+	})
+}
+
+func (s *formatTestSuite) Test_Decode_JSON(c *C) {
+	doc, err := Decode(JSON, []byte(`{"port": 8080}`))
+	c.Assert(err, IsNil)
+	c.Assert(doc, DeepEquals, map[string]interface{}{"port": float64(8080)})
+
+	_, err = Decode(JSON, []byte(`port: 8080`))
+	c.Assert(err, NotNil)
+
+	_, err = Decode(Format("xml"), []byte(`<a/>`))
+	c.Assert(err, ErrorMatches, "unknown format 'xml'")
+}
@@ -0,0 +1,200 @@
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/iostrovok/yacs-go/yacs-go/jsonpointer"
+)
+
+// Position is the place of a value in the source. Line and Column start from 1.
+type Position struct {
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	if p.Column == 0 {
+		return strconv.Itoa(p.Line)
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Positions are places of values by JSON pointers. Members of objects point to their keys.
+type Positions map[string]Position
+
+// Find returns the position of the value or of the nearest container of it.
+func (ps Positions) Find(pointer string) (Position, bool) {
+	p, err := jsonpointer.Parse(pointer)
+	if err != nil {
+		return Position{}, false
+	}
+
+	for {
+		if pos, find := ps[p.String()]; find {
+			return pos, true
+		}
+		if len(p) == 0 {
+			return Position{}, false
+		}
+		p = p.Parent()
+	}
+}
+
+// SyntaxError is an error of decoding with the place of the problem.
+type SyntaxError struct {
+	Position
+	Err error
+}
+
+func (e *SyntaxError) Error() string {
+	return e.Position.String() + ": " + e.Err.Error()
+}
+
+// Unwrap returns the original error of the decoder.
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+var yamlLine = regexp.MustCompile(`^yaml: line (\d+): `)
+
+// syntaxError adds position to errors of the decoders.
+func syntaxError(body []byte, err error) error {
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		// Offset is after the wrong byte.
+		return &SyntaxError{Position: offsetPosition(body, e.Offset-1), Err: err}
+	case *json.UnmarshalTypeError:
+		return &SyntaxError{Position: offsetPosition(body, e.Offset), Err: err}
+	}
+
+	if m := yamlLine.FindStringSubmatch(err.Error()); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		return &SyntaxError{Position: Position{Line: line}, Err: fmt.Errorf("yaml: %s", err.Error()[len(m[0]):])}
+	}
+
+	return err
+}
+
+// offsetPosition converts offset in bytes to line and column.
+func offsetPosition(body []byte, offset int64) Position {
+	if offset > int64(len(body)) {
+		offset = int64(len(body))
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	before := body[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	return Position{Line: line, Column: column}
+}
+
+// Locate returns positions of all values of the document.
+func Locate(f Format, body []byte) (Positions, error) {
+	out := Positions{}
+
+	switch f {
+	case JSON:
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		return out, locateJSON(dec, body, nil, out)
+
+	case YAML:
+		var node yaml.Node
+		if err := yaml.Unmarshal(body, &node); err != nil {
+			return nil, err
+		}
+		locateYAML(&node, nil, out)
+		return out, nil
+	}
+
+	return nil, fmt.Errorf("unknown format '%s'", f)
+}
+
+// valueStart skips separators between tokens of JSON.
+func valueStart(body []byte, offset int64) int64 {
+	for offset < int64(len(body)) {
+		switch body[offset] {
+		case ' ', '\t', '\r', '\n', ',', ':':
+			offset++
+			continue
+		}
+		break
+	}
+	return offset
+}
+
+func locateJSON(dec *json.Decoder, body []byte, p jsonpointer.Pointer, out Positions) error {
+
+	start := valueStart(body, dec.InputOffset())
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	if _, find := out[p.String()]; !find {
+		out[p.String()] = offsetPosition(body, start)
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		for dec.More() {
+			keyStart := valueStart(body, dec.InputOffset())
+			key, err := dec.Token()
+			if err != nil {
+				return err
+			}
+
+			next := p.Append(fmt.Sprint(key))
+			out[next.String()] = offsetPosition(body, keyStart)
+			if err := locateJSON(dec, body, next, out); err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token()
+
+	case json.Delim('['):
+		for i := 0; dec.More(); i++ {
+			if err := locateJSON(dec, body, p.Append(strconv.Itoa(i)), out); err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token()
+	}
+
+	return err
+}
+
+func locateYAML(node *yaml.Node, p jsonpointer.Pointer, out Positions) {
+
+	if _, find := out[p.String()]; !find {
+		out[p.String()] = Position{Line: node.Line, Column: node.Column}
+	}
+
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) > 0 {
+			locateYAML(node.Content[0], p, out)
+		}
+
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			next := p.Append(key.Value)
+			out[next.String()] = Position{Line: key.Line, Column: key.Column}
+			locateYAML(value, next, out)
+		}
+
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			locateYAML(item, p.Append(strconv.Itoa(i)), out)
+		}
+	}
+}
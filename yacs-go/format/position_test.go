@@ -0,0 +1,62 @@
+package format
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type positionTestSuite struct{}
+
+var _ = Suite(&positionTestSuite{})
+
+func (s *positionTestSuite) Test_Locate_JSON(c *C) {
+	body := []byte("{\n    \"a\": 1,\n    \"b\": {\"c\": [true, \"x\"]}\n}\n")
+
+	pos, err := Locate(JSON, body)
+	c.Assert(err, IsNil)
+	c.Assert(pos, DeepEquals, Positions{
+		"":       {Line: 1, Column: 1},
+		"/a":     {Line: 2, Column: 5},
+		"/b":     {Line: 3, Column: 5},
+		"/b/c":   {Line: 3, Column: 11},
+		"/b/c/0": {Line: 3, Column: 17},
+		"/b/c/1": {Line: 3, Column: 23},
+	})
+}
+
+func (s *positionTestSuite) Test_Locate_YAML(c *C) {
+	body := []byte("a: 1\nb:\n  c:\n    - true\n    - x\n")
+
+	pos, err := Locate(YAML, body)
+	c.Assert(err, IsNil)
+	c.Assert(pos, DeepEquals, Positions{
+		"":       {Line: 1, Column: 1},
+		"/a":     {Line: 1, Column: 1},
+		"/b":     {Line: 2, Column: 1},
+		"/b/c":   {Line: 3, Column: 3},
+		"/b/c/0": {Line: 4, Column: 7},
+		"/b/c/1": {Line: 5, Column: 7},
+	})
+}
+
+func (s *positionTestSuite) Test_Positions_Find(c *C) {
+	pos := Positions{"": {1, 1}, "/a": {2, 5}}
+
+	p, find := pos.Find("/a/b/c")
+	c.Assert(find, Equals, true)
+	c.Assert(p, Equals, Position{2, 5})
+
+	_, find = Positions{}.Find("/a")
+	c.Assert(find, Equals, false)
+}
+
+func (s *positionTestSuite) Test_Decode_SyntaxError(c *C) {
+	_, err := Decode(JSON, []byte("{\n  \"a\": 1,\n  \"b\": ]\n}"))
+	c.Assert(err, FitsTypeOf, &SyntaxError{})
+	c.Assert(err.(*SyntaxError).Position, Equals, Position{Line: 3, Column: 8})
+	c.Assert(err, ErrorMatches, "3:8: invalid character.*")
+
+	_, err = Decode(YAML, []byte("a: 1\nb: [\n"))
+	c.Assert(err, FitsTypeOf, &SyntaxError{})
+	c.Assert(err.(*SyntaxError).Line, Equals, 2)
+	c.Assert(err, ErrorMatches, "2: yaml: .*")
+}
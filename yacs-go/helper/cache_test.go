@@ -0,0 +1,87 @@
+package helper
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+type cacheTestSuite struct {
+	dir string
+}
+
+var _ = Suite(&cacheTestSuite{})
+
+func (s *cacheTestSuite) SetUpTest(c *C) {
+	s.dir = c.MkDir()
+	s.write(c, "main.json", `{"a": {"$ref": "part.json"}, "b": {"$ref": "part.json#/v"}}`)
+	s.write(c, "part.json", `{"v": "first"}`)
+}
+
+func (s *cacheTestSuite) write(c *C, name, body string) {
+	c.Assert(ioutil.WriteFile(filepath.Join(s.dir, name), []byte(body), os.FileMode(0666)), IsNil)
+}
+
+func (s *cacheTestSuite) Test_PerRun(c *C) {
+	p := NewProcessor(WithBaseDir(s.dir))
+
+	res, err := p.ProcessURI("main.json")
+	c.Assert(err, IsNil)
+	c.Assert(res.Doc, DeepEquals, map[string]interface{}{
+		"a": map[string]interface{}{"v": "first"},
+		"b": "first",
+	})
+
+	// main.json + part.json are loaded, the second reference hits cache.
+	stats := p.CacheStats()
+	c.Assert(stats.Misses, Equals, int64(2))
+	c.Assert(stats.Hits, Equals, int64(1))
+
+	s.write(c, "part.json", `{"v": "second"}`)
+	res, err = p.ProcessURI("main.json")
+	c.Assert(err, IsNil)
+	c.Assert(res.Doc.(map[string]interface{})["b"], Equals, "second")
+}
+
+func (s *cacheTestSuite) Test_None(c *C) {
+	p := NewProcessor(WithBaseDir(s.dir), WithCachePolicy(CacheNone))
+
+	_, err := p.ProcessURI("main.json")
+	c.Assert(err, IsNil)
+	c.Assert(p.CacheStats().Misses, Equals, int64(3))
+	c.Assert(p.CacheStats().Hits, Equals, int64(0))
+}
+
+func (s *cacheTestSuite) Test_Validated(c *C) {
+	p := NewProcessor(WithBaseDir(s.dir), WithCachePolicy(CacheValidated))
+
+	_, err := p.ProcessURI("main.json")
+	c.Assert(err, IsNil)
+
+	res, err := p.ProcessURI("main.json")
+	c.Assert(err, IsNil)
+	c.Assert(res.Doc.(map[string]interface{})["b"], Equals, "first")
+	c.Assert(p.CacheStats().Misses, Equals, int64(2))
+	c.Assert(p.CacheStats().Entries, Equals, 2)
+
+	// The size of file is changed, so the cached document is stale.
+	s.write(c, "part.json", `{"v": "changed"}`)
+	res, err = p.ProcessURI("main.json")
+	c.Assert(err, IsNil)
+	c.Assert(res.Doc.(map[string]interface{})["b"], Equals, "changed")
+	c.Assert(p.CacheStats().Stale, Equals, int64(1))
+}
+
+func (s *cacheTestSuite) Test_LRU(c *C) {
+	p := NewProcessor(WithBaseDir(s.dir), WithCachePolicy(CacheLRU), WithLimits(Limits{CacheEntries: 1}))
+
+	_, err := p.ProcessURI("main.json")
+	c.Assert(err, IsNil)
+	c.Assert(p.CacheStats().Entries, Equals, 1)
+	c.Assert(p.CacheStats().Evictions, Equals, int64(1))
+
+	p.ResetCache()
+	c.Assert(p.CacheStats().Entries, Equals, 0)
+}
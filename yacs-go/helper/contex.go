@@ -1,22 +1,54 @@
 package helper
 
 import (
+	"errors"
+	"net/url"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
+
+	"github.com/iostrovok/yacs-go/yacs-go/cache"
+	"github.com/iostrovok/yacs-go/yacs-go/format"
+	"github.com/iostrovok/yacs-go/yacs-go/jsonpointer"
+	"github.com/iostrovok/yacs-go/yacs-go/utils"
 )
 
 // Context is just internal sturture.
 type Context struct {
-	uri string
+	proc  *Processor
+	cache cache.Cache
+	uri   string
+	// root is not resolved document which is referred by uri, "#/path" references are resolved against it.
+	root interface{}
+	// chain is the list of references which are being resolved now.
+	chain []RefStep
+	// origins keeps the source of resolved maps, it's shared by copies.
+	origins map[uintptr]origin
+	// refs are targets of "$ref" by their places, it's shared by copies.
+	refs map[RefStep]RefStep
+	// positions are places of values in loaded documents by URI, it's shared by copies.
+	positions map[string]format.Positions
+	// requested are URIs of all documents which have been requested, loaded or not. It's shared by copies.
+	requested map[string]bool
 }
 
-func newContext() *Context {
-	return &Context{}
+func newContext(p *Processor) *Context {
+	return &Context{
+		proc:    p,
+		cache:   p.sessionCache(),
+		origins: map[uintptr]origin{},
+		refs:    map[RefStep]RefStep{},
+
+		positions: map[string]format.Positions{},
+		requested: map[string]bool{},
+	}
 }
 
+// getDir returns absolute URI of the reference which is relative to the current document.
 func (c *Context) getDir(URI string) string {
 
-	if filepath.IsAbs(URI) {
+	if utils.IsRemoteURI(URI) {
 		return URI
 	}
 
@@ -24,20 +56,146 @@ func (c *Context) getDir(URI string) string {
 		return c.uri
 	}
 
-	if strings.Index(URI, "#") == 0 {
-		return filepath.Clean(filepath.Join(c.uri, URI))
+	base := c.uri
+	if base == "" {
+		// Top level document: relative URIs are started from the base dir.
+		base = c.proc.baseDir
+		if utils.IsRemoteURI(base) && !strings.HasSuffix(base, "/") {
+			base += "/"
+		}
+	}
+
+	if utils.IsRemoteURI(base) {
+		return resolveURL(base, URI)
+	}
+
+	if filepath.IsAbs(URI) {
+		return URI
+	}
+
+	if c.uri == "" {
+		return filepath.Clean(filepath.Join(base, URI))
 	}
 
-	dir, _ := filepath.Split(c.uri)
+	dir, _ := filepath.Split(base)
 	return filepath.Clean(filepath.Join(dir, URI))
 }
 
+// resolveURL resolves relative reference against remote base URL.
+func resolveURL(base, ref string) string {
+	b, err := url.Parse(base)
+	if err != nil {
+		return ref
+	}
+
+	r, err := url.Parse(filepath.ToSlash(ref))
+	if err != nil {
+		return ref
+	}
+
+	return b.ResolveReference(r).String()
+}
+
+// enter adds the reference to the chain. It fails on loops and too deep chains.
+func (c *Context) enter(step RefStep) error {
+
+	for i, s := range c.chain {
+		if s == step {
+			chain := append([]RefStep{}, c.chain[i:]...)
+			return &RefCycleError{Chain: append(chain, step)}
+		}
+	}
+
+	c.chain = append(c.chain, step)
+
+	if limit := c.proc.limits.MaxRefDepth; limit > 0 && len(c.chain) > limit {
+		return &RefDepthError{Limit: limit, Chain: append([]RefStep{}, c.chain...)}
+	}
+
+	return nil
+}
+
 func (c *Context) setURI(URI string) {
 	c.uri = URI
 }
 
 func (c *Context) copy() *Context {
 	return &Context{
-		uri: c.uri,
+		proc:    c.proc,
+		cache:   c.cache,
+		uri:     c.uri,
+		root:    c.root,
+		chain:   append([]RefStep{}, c.chain...),
+		origins: c.origins,
+		refs:    c.refs,
+
+		positions: c.positions,
+		requested: c.requested,
+	}
+}
+
+// pointer returns JSON pointer of the current document in its file.
+func (c *Context) pointer() jsonpointer.Pointer {
+	if len(c.chain) == 0 {
+		return nil
+	}
+
+	p, err := jsonpointer.Parse(c.chain[len(c.chain)-1].Pointer)
+	if err != nil {
+		return nil
+	}
+	return p
+}
+
+// dependencies returns URIs of all requested documents. Documents which have failed to load are there too,
+// so their fixes may be found by the caller.
+func (c *Context) dependencies() []string {
+	out := make([]string, 0, len(c.requested))
+	for uri := range c.requested {
+		out = append(out, uri)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// origin is the source of the resolved map. The map itself is kept,
+// so its address can't be reused by another map during the session.
+type origin struct {
+	m    map[string]interface{}
+	step RefStep
+}
+
+// setOrigin saves the source of the map. Maps are distinguished by identity.
+func (c *Context) setOrigin(m map[string]interface{}, p jsonpointer.Pointer) {
+	c.origins[reflect.ValueOf(m).Pointer()] = origin{m: m, step: RefStep{URI: c.uri, Pointer: p.String()}}
+}
+
+// originOf returns the source of the map which has been resolved.
+func originOf(origins map[uintptr]origin, node interface{}) (RefStep, bool) {
+	m, ok := node.(map[string]interface{})
+	if !ok || origins == nil {
+		return RefStep{}, false
+	}
+
+	o, find := origins[reflect.ValueOf(m).Pointer()]
+	return o.step, find
+}
+
+// sourceError adds the place of the value in the source to err.
+// Errors which have the place already are returned as is, so the deepest place wins.
+func (c *Context) sourceError(step RefStep, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var se *SourceError
+	if errors.As(err, &se) {
+		return err
+	}
+
+	se = &SourceError{URI: step.URI, Pointer: step.Pointer, Err: err}
+	if c != nil {
+		se.Position, _ = c.positions[step.URI].Find(step.Pointer)
 	}
+	return se
 }
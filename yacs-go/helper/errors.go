@@ -0,0 +1,140 @@
+package helper
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/iostrovok/yacs-go/yacs-go/format"
+)
+
+// RefStep is a single reference in the chain of resolving.
+type RefStep struct {
+	URI     string
+	Pointer string
+}
+
+func (s RefStep) String() string {
+	return s.URI + "#" + s.Pointer
+}
+
+func chainString(chain []RefStep) string {
+	strs := make([]string, len(chain))
+	for i, s := range chain {
+		strs[i] = s.String()
+	}
+	return strings.Join(strs, " -> ")
+}
+
+// RefCycleError is returned when references form a loop.
+// The first and the last steps of Chain are the same.
+type RefCycleError struct {
+	Chain []RefStep
+}
+
+func (e *RefCycleError) Error() string {
+	return "circular reference: " + chainString(e.Chain)
+}
+
+// RefDepthError is returned when the chain of references is longer than Limits.MaxRefDepth.
+type RefDepthError struct {
+	Limit int
+	Chain []RefStep
+}
+
+func (e *RefDepthError) Error() string {
+	return fmt.Sprintf("too deep references (limit %d): %s", e.Limit, chainString(e.Chain))
+}
+
+// LockedValueError is reported when a child tries to override or to delete
+// a value which is locked by "@lock_names" of the parent.
+type LockedValueError struct {
+	// Pointer is JSON pointer of the value in the result.
+	Pointer string
+	// LockedBy is the source of the map with "@lock_names", it's empty if unknown.
+	LockedBy string
+	// OverriddenBy is the source of the map which tries to override the value, it's empty if unknown.
+	OverriddenBy string
+}
+
+func (e *LockedValueError) Error() string {
+	msg := "locked value " + e.Pointer
+	if e.LockedBy != "" {
+		msg += " (locked by " + e.LockedBy + ")"
+	}
+	msg += " can't be overridden"
+	if e.OverriddenBy != "" {
+		msg += " by " + e.OverriddenBy
+	}
+	return msg
+}
+
+// SourceError is an error with the place of the problem in the source document.
+type SourceError struct {
+	// URI of the document, it's empty for ProcessReader and ProcessValue.
+	URI string
+	// Pointer is JSON pointer of the value in the document, it's empty for errors of syntax.
+	Pointer string
+	// Position is zero if it's unknown.
+	format.Position
+	Err error
+}
+
+func (e *SourceError) Error() string {
+	place := e.URI
+	switch {
+	case e.Line > 0 && place != "":
+		place += ":" + e.Position.String()
+	case e.Line > 0:
+		place = e.Position.String()
+	case e.Pointer != "":
+		place += "#" + e.Pointer
+	}
+
+	if place == "" {
+		return e.Err.Error()
+	}
+	return place + ": " + e.Err.Error()
+}
+
+// Unwrap returns the original error.
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
+
+// StageError is an error of the processing step.
+type StageError struct {
+	Stage Stage
+	Err   error
+	// Dependencies are sorted URIs of documents which have been requested before the error, see Output.Dependencies.
+	Dependencies []string
+}
+
+func (e *StageError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the original error.
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorStage returns the name of the step which has failed: "resolve", "inherit", "validate"
+// or "load" for errors of loading of the top level document.
+func ErrorStage(err error) string {
+	var se *StageError
+	if errors.As(err, &se) {
+		return se.Stage.String()
+	}
+	return "load"
+}
+
+// ErrorDependencies returns URIs of documents which have been requested before the error.
+// It's empty for errors of loading of the top level document.
+func ErrorDependencies(err error) []string {
+	var se *StageError
+	if errors.As(err, &se) {
+		return se.Dependencies
+	}
+	return nil
+}
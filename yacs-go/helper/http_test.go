@@ -0,0 +1,113 @@
+package helper
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type httpTestSuite struct {
+	server *httptest.Server
+	files  map[string]string
+	header http.Header
+}
+
+var _ = Suite(&httpTestSuite{})
+
+func (s *httpTestSuite) SetUpTest(c *C) {
+	s.files = map[string]string{
+		"/configs/main.json":     `{"db": {"$ref": "parts/db.json#/db"}, "owner": {"$ref": "/owner.json#/name"}}`,
+		"/configs/parts/db.json": `{"db": {"host": "remote", "defaults": {"$ref": "../defaults.json"}}}`,
+		"/configs/defaults.json": `{"port": 5432}`,
+		"/owner.json":            `{"name": "Frank Gannett"}`,
+	}
+
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.header = r.Header
+		if r.URL.Path == "/slow.json" {
+			time.Sleep(200 * time.Millisecond)
+		}
+
+		body, find := s.files[r.URL.Path]
+		if !find {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("ETag", `"`+body+`"`)
+		w.Write([]byte(body))
+	}))
+}
+
+func (s *httpTestSuite) TearDownTest(c *C) {
+	s.server.Close()
+}
+
+var remoteMain = map[string]interface{}{
+	"db": map[string]interface{}{
+		"host":     "remote",
+		"defaults": map[string]interface{}{"port": float64(5432)},
+	},
+	"owner": "Frank Gannett",
+}
+
+func (s *httpTestSuite) Test_ProcessURI(c *C) {
+	res, err := NewProcessor().ProcessURI(s.server.URL + "/configs/main.json")
+	c.Assert(err, IsNil)
+	c.Assert(res.Doc, DeepEquals, remoteMain)
+}
+
+func (s *httpTestSuite) Test_BaseDir(c *C) {
+	res, err := NewProcessor(WithBaseDir(s.server.URL + "/configs")).ProcessURI("main.json")
+	c.Assert(err, IsNil)
+	c.Assert(res.Doc, DeepEquals, remoteMain)
+}
+
+func (s *httpTestSuite) Test_FileRefersURL(c *C) {
+	dir := c.MkDir()
+	body := `{"owner": {"$ref": "` + s.server.URL + `/owner.json#/name"}}`
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "local.json"), []byte(body), os.FileMode(0666)), IsNil)
+
+	res, err := NewProcessor().ProcessURI(filepath.Join(dir, "local.json"))
+	c.Assert(err, IsNil)
+	c.Assert(res.Doc, DeepEquals, map[string]interface{}{"owner": "Frank Gannett"})
+}
+
+func (s *httpTestSuite) Test_Header(c *C) {
+	_, err := NewProcessor(WithHTTPHeader("Authorization", "Bearer token")).ProcessURI(s.server.URL + "/owner.json")
+	c.Assert(err, IsNil)
+	c.Assert(s.header.Get("Authorization"), Equals, "Bearer token")
+}
+
+func (s *httpTestSuite) Test_NotFound(c *C) {
+	_, err := NewProcessor().ProcessURI(s.server.URL + "/missing.json")
+	c.Assert(err, ErrorMatches, ".*404 Not Found")
+}
+
+func (s *httpTestSuite) Test_Timeout(c *C) {
+	s.files["/slow.json"] = `{}`
+	_, err := NewProcessor(WithHTTPTimeout(50 * time.Millisecond)).ProcessURI(s.server.URL + "/slow.json")
+	c.Assert(err, ErrorMatches, ".*(Timeout|deadline).*")
+}
+
+func (s *httpTestSuite) Test_CacheValidated(c *C) {
+	p := NewProcessor(WithCachePolicy(CacheValidated))
+
+	_, err := p.ProcessURI(s.server.URL + "/owner.json")
+	c.Assert(err, IsNil)
+
+	s.files["/owner.json"] = `{"name": "William Hearst"}`
+	res, err := p.ProcessURI(s.server.URL + "/owner.json")
+	c.Assert(err, IsNil)
+	c.Assert(res.Doc, DeepEquals, map[string]interface{}{"name": "William Hearst"})
+	c.Assert(p.CacheStats().Stale, Equals, int64(1))
+}
+
+func (s *httpTestSuite) Test_UnknownScheme(c *C) {
+	_, err := NewProcessor().ProcessURI("ftp://example.com/child.json")
+	c.Assert(err, ErrorMatches, ".*no loader for scheme 'ftp'")
+}
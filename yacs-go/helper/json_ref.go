@@ -8,7 +8,7 @@ value for this key.  For instance if this document had its JSON References
 resolved:
     {
         "gannett_founder": "Frank Gannett",
-        "hearst_rival": {"$ref": "///gannett_founder"}
+        "hearst_rival": {"$ref": "#/gannett_founder"}
     }
 it would look like:
     {
@@ -17,17 +17,16 @@ it would look like:
     }
 JSON References can also refer to other documents by giving a full URI such as:
     {
-        "hearst_rival": {"$ref": "http://example.com/gannett.json///founder"}
+        "hearst_rival": {"$ref": "http://example.com/gannett.json#/founder"}
     }
 
 */
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"strings"
 
+	"github.com/iostrovok/yacs-go/yacs-go/jsonpointer"
 	"github.com/iostrovok/yacs-go/yacs-go/myconst"
 	"github.com/iostrovok/yacs-go/yacs-go/utils"
 )
@@ -46,28 +45,18 @@ func extractJSONrefURI(value interface{}) (string, error) {
 	return out, nil
 }
 
-func fetchURL(url string) ([]byte, error) {
-	// response = urllib2.urlopen(url)
-	// return response.read()
-	return ioutil.ReadFile(url)
-}
-
 func fetchURI(uri string, context *Context) (interface{}, error) {
 	// Fetch URI as JSON.
 	// url is what we'll actually end up retrieving
-	// url := filepath.Clean(filepath.Join(context.getDir(), uri))
 	url := context.getDir(uri)
+	context.requested[url] = true
 
-	uriData, err := fetchURL(url)
-	if err != nil {
-		return nil, err
-	}
-
-	var doc interface{}
-	err = json.Unmarshal(uriData, &doc)
+	doc, positions, err := context.proc.fetch(url, context.cache)
 	if err == nil {
 		// We just retrieved a new URL so the context has changed.
 		context.setURI(url)
+		context.root = doc
+		context.positions[url] = positions
 	}
 
 	return doc, err
@@ -76,23 +65,28 @@ func fetchURI(uri string, context *Context) (interface{}, error) {
 func getRefURI(uri string, docIn interface{}, context *Context) (interface{}, error) {
 	/* Returns the value designated by the provided JSON Reference URI.
 
-	   If the reference is local, retrieves it from 'context' parameter.
+	   If the reference is local ("#/path"), retrieves it from the root of the current document.
 	   If the reference is not local, consults the registry for the document.
 	*/
 
-	doc, err := utils.DeepCopy(docIn)
+	// JSON Reference URIs look mostly like URLs.
+	base, pointer, err := utils.URLDefrag(uri)
 	if err != nil {
 		return nil, err
 	}
 
-	// JSON Reference URIs look mostly like URLs.
-	base, pointer, err := utils.URLDefrag(uri)
-	if err != nil {
+	local := strings.HasPrefix(uri, "#") && context.root != nil
+	if local {
+		base = "."
+	}
+
+	if err := context.enter(RefStep{URI: context.getDir(base), Pointer: pointer}); err != nil {
 		return nil, err
 	}
 
-	// There's a path, so we need to fetch the document.
-	if base != "" {
+	doc := context.root
+	if !local {
+		// There's a path, so we need to fetch the document.
 		doc, err = fetchURI(base, context)
 		if err != nil {
 			return nil, err
@@ -100,30 +94,21 @@ func getRefURI(uri string, docIn interface{}, context *Context) (interface{}, er
 	}
 
 	// The pointer piece of a JSON Reference appears after an (optional) hash.
-	if pointer == "" {
-		return doc, nil
-	}
-
 	return resolvePointer(doc, pointer)
 }
 
+// resolvePointer returns a copy of value by JSON pointer.
+// A missing value is *jsonpointer.NotFoundError.
 func resolvePointer(node interface{}, path string) (interface{}, error) {
 
-	paths := strings.Split(strings.TrimPrefix(strings.TrimSuffix(path, "/"), "/"), "/")
-	if path == "/" || path == "" || len(paths) == 0 {
-		return utils.DeepCopy(node)
-	}
-
-	next, find := utils.GetKeyFromAnyInteface(node, paths[0])
-	if !find {
-		return nil, nil
+	p, err := jsonpointer.Parse(path)
+	if err != nil {
+		return nil, err
 	}
 
-	for _, v := range paths[1:] {
-		next, find = utils.GetKeyFromAnyInteface(next, v)
-		if !find {
-			return nil, nil
-		}
+	next, err := jsonpointer.Get(node, p)
+	if err != nil {
+		return nil, err
 	}
 
 	return utils.DeepCopy(next)
@@ -0,0 +1,153 @@
+package helper
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/iostrovok/yacs-go/yacs-go/jsonpointer"
+
+	. "gopkg.in/check.v1"
+)
+
+type jsonRefTestSuite struct {
+	dir string
+}
+
+var _ = Suite(&jsonRefTestSuite{})
+
+func (s *jsonRefTestSuite) SetUpTest(c *C) {
+	s.dir = c.MkDir()
+}
+
+func (s *jsonRefTestSuite) write(c *C, name, body string) {
+	c.Assert(ioutil.WriteFile(filepath.Join(s.dir, name), []byte(body), os.FileMode(0666)), IsNil)
+}
+
+func (s *jsonRefTestSuite) path(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+func (s *jsonRefTestSuite) Test_Cycle_Self(c *C) {
+	s.write(c, "self.json", `{"me": {"$ref": "self.json"}}`)
+
+	_, err := NewProcessor().ProcessURI(s.path("self.json"))
+	var cycle *RefCycleError
+	ok := errors.As(err, &cycle)
+	c.Assert(ok, Equals, true)
+	c.Assert(cycle.Chain, DeepEquals, []RefStep{
+		{URI: s.path("self.json")},
+		{URI: s.path("self.json")},
+	})
+}
+
+func (s *jsonRefTestSuite) Test_Cycle_AB(c *C) {
+	s.write(c, "a.json", `{"b": {"$ref": "b.json#/x"}}`)
+	s.write(c, "b.json", `{"x": {"a": {"$ref": "a.json"}}}`)
+
+	_, err := NewProcessor().ProcessURI(s.path("a.json"))
+	var cycle *RefCycleError
+	ok := errors.As(err, &cycle)
+	c.Assert(ok, Equals, true)
+	c.Assert(cycle.Chain, DeepEquals, []RefStep{
+		{URI: s.path("a.json")},
+		{URI: s.path("b.json"), Pointer: "/x"},
+		{URI: s.path("a.json")},
+	})
+	c.Assert(err, ErrorMatches, ".*b.json:1:14: circular reference: .*a.json# -> .*b.json#/x -> .*a.json#")
+}
+
+func (s *jsonRefTestSuite) Test_NotCycle(c *C) {
+	// The same document is referred twice but not in loop.
+	s.write(c, "main.json", `{"a": {"$ref": "part.json"}, "b": [{"$ref": "part.json"}]}`)
+	s.write(c, "part.json", `{"v": 1}`)
+
+	res, err := NewProcessor().ProcessURI(s.path("main.json"))
+	c.Assert(err, IsNil)
+	c.Assert(res.Doc, DeepEquals, map[string]interface{}{
+		"a": map[string]interface{}{"v": float64(1)},
+		"b": []interface{}{map[string]interface{}{"v": float64(1)}},
+	})
+}
+
+func (s *jsonRefTestSuite) Test_MaxRefDepth(c *C) {
+	s.write(c, "1.json", `{"next": {"$ref": "2.json"}}`)
+	s.write(c, "2.json", `{"next": {"$ref": "3.json"}}`)
+	s.write(c, "3.json", `{"end": true}`)
+
+	_, err := NewProcessor(WithLimits(Limits{MaxRefDepth: 3})).ProcessURI(s.path("1.json"))
+	c.Assert(err, IsNil)
+
+	_, err = NewProcessor(WithLimits(Limits{MaxRefDepth: 2})).ProcessURI(s.path("1.json"))
+	var depth *RefDepthError
+	ok := errors.As(err, &depth)
+	c.Assert(ok, Equals, true)
+	c.Assert(depth.Limit, Equals, 2)
+	c.Assert(depth.Chain, HasLen, 3)
+}
+
+func (s *jsonRefTestSuite) Test_Pointer(c *C) {
+	s.write(c, "main.json", `{"a": {"$ref": "part.json#/a~1b/0"}, "b": {"$ref": "part.json#/c%25d"}, "n": {"$ref": "part.json#/null"}}`)
+	s.write(c, "part.json", `{"a/b": ["first"], "c%d": 2, "null": null}`)
+
+	res, err := NewProcessor().ProcessURI(s.path("main.json"))
+	c.Assert(err, IsNil)
+	c.Assert(res.Doc, DeepEquals, map[string]interface{}{"a": "first", "b": float64(2), "n": nil})
+}
+
+func (s *jsonRefTestSuite) Test_Pointer_Missing(c *C) {
+	s.write(c, "main.json", `{"a": {"$ref": "part.json#/missing"}}`)
+	s.write(c, "part.json", `{}`)
+
+	_, err := NewProcessor().ProcessURI(s.path("main.json"))
+	var target *jsonpointer.NotFoundError
+	ok := errors.As(err, &target)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *jsonRefTestSuite) Test_Local(c *C) {
+	s.write(c, "main.json", `{
+		"gannett_founder": "Frank Gannett",
+		"hearst_rival": {"$ref": "#/gannett_founder"},
+		"sections": {
+			"base": {"name": {"$ref": "#/gannett_founder"}, "city": "Rochester"},
+			"child": {"@parent": {"$ref": "#/sections/base"}, "city": "Ithaca"}
+		},
+		"child": {"$ref": "#/sections/child"},
+		"remote": {"$ref": "part.json#/rival"}
+	}`)
+	// "#/..." in part.json refers to part.json, not to main.json.
+	s.write(c, "part.json", `{"gannett_founder": "William Hearst", "rival": {"$ref": "#/gannett_founder"}}`)
+
+	res, err := NewProcessor().ProcessURI(s.path("main.json"))
+	c.Assert(err, IsNil)
+
+	doc := res.Doc.(map[string]interface{})
+	c.Assert(doc["hearst_rival"], Equals, "Frank Gannett")
+	c.Assert(doc["remote"], Equals, "William Hearst")
+	c.Assert(doc["child"], DeepEquals, map[string]interface{}{"name": "Frank Gannett", "city": "Ithaca"})
+}
+
+func (s *jsonRefTestSuite) Test_Local_Value(c *C) {
+	doc := map[string]interface{}{
+		"definitions": map[string]interface{}{"port": float64(80)},
+		"port":        map[string]interface{}{"$ref": "#/definitions/port"},
+	}
+
+	res, err := NewProcessor().ProcessValue(doc)
+	c.Assert(err, IsNil)
+	c.Assert(res.Doc.(map[string]interface{})["port"], Equals, float64(80))
+}
+
+func (s *jsonRefTestSuite) Test_Local_Cycle(c *C) {
+	doc := map[string]interface{}{
+		"a": map[string]interface{}{"$ref": "#/b"},
+		"b": map[string]interface{}{"x": map[string]interface{}{"$ref": "#/a"}},
+	}
+
+	_, err := NewProcessor().ProcessValue(doc)
+	var target *RefCycleError
+	ok := errors.As(err, &target)
+	c.Assert(ok, Equals, true)
+}
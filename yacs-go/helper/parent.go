@@ -1,11 +1,19 @@
 package helper
 
 import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/iostrovok/yacs-go/yacs-go/jsonpointer"
 	"github.com/iostrovok/yacs-go/yacs-go/myconst"
 	"github.com/iostrovok/yacs-go/yacs-go/utils"
 )
 
-// getLockNames extracts string keys from interface and convert to map[string]bool
+// getLockNames extracts string keys from interface and convert to map[string]bool.
+// A key may be a path, see getLockPaths.
 func getLockNames(doc interface{}) map[string]bool {
 	/*
 		It ignores bool, int64, float64, []bool, []int64, []float64.
@@ -67,98 +75,726 @@ func getLockNames(doc interface{}) map[string]bool {
 	return out
 }
 
-func replaceExceptLocked(original, replacement interface{}) interface{} {
+// getDeletePaths extracts "@delete" and "@unset" of the child as JSON pointers.
+func getDeletePaths(doc interface{}) ([]jsonpointer.Pointer, error) {
 	/*
-	   Overwrites values in the original dict with those in the replacement dict,
-	   unless the key is in @lock_names.
+		A name which starts with "/" is JSON pointer, other names are keys of the current level.
+
+		Exmaples:
+
+			"@delete" : "header"
+
+			"@unset" : [
+				"footer",
+				"/database/password"
+			]
 	*/
 
-	if utils.IsMapStringInterface(original) && utils.IsMapStringInterface(replacement) {
+	out := []jsonpointer.Pointer{}
 
-		// See comment to getLockNames() function
-		lockNames := getLockNames(original)
+	for _, key := range []string{myconst.DeleteKeyName, myconst.UnsetKeyName} {
+		names, find := utils.GetKeyFromInteface(doc, key)
+		if !find {
+			continue
+		}
 
-		out := original.(map[string]interface{})
+		list := utils.ToListInterface(names)
+		for _, v := range list {
+			name, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: '%v' must be a string", key, v)
+			}
 
-		// Iterate through the replacement dictionary.
-		for key, value := range replacement.(map[string]interface{}) {
-			if lockNames[key] {
-				/*
-					Don't process key from "@lock_names" keys.
-					See comment to getLockNames() function
-				*/
+			if !strings.HasPrefix(name, "/") {
+				out = append(out, jsonpointer.Pointer{name})
 				continue
 			}
 
-			originalValues, originalFind := utils.GetKeyFromInteface(original, key)
+			p, err := jsonpointer.Parse(name)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", key, err)
+			}
+			out = append(out, p)
+		}
+	}
+
+	return out, nil
+}
+
+// lockPath is a path of locked value relative to the map with "@lock_names".
+// "*" matches any key of map or index of array.
+type lockPath []string
+
+const lockWildcard = "*"
+
+// getLockPaths converts names from "@lock_names" to paths.
+func getLockPaths(doc interface{}) ([]lockPath, error) {
+	/*
+		Exmaples:
+
+			"header"             => header
+			"/database/password" => JSON pointer
+			"database/password"  => JSON pointer without leading "/"
+			"servers.*.port"     => dotted path, port of each server
+	*/
+
+	names := getLockNames(doc)
+
+	out := make([]lockPath, 0, len(names))
+	for name := range names {
+		switch {
+		case strings.Contains(name, "/"):
+			if !strings.HasPrefix(name, "/") {
+				name = "/" + name
+			}
+			p, err := jsonpointer.Parse(name)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", myconst.LockKeyName, err)
+			}
+			out = append(out, lockPath(p))
+
+		default:
+			out = append(out, lockPath(strings.Split(name, ".")))
+		}
+	}
+
+	// makes the order of errors stable
+	sort.Slice(out, func(i, j int) bool {
+		return jsonpointer.Pointer(out[i]).String() < jsonpointer.Pointer(out[j]).String()
+	})
+
+	return out, nil
+}
+
+// overlaps checks that p is the locked value, is inside it or contains it.
+func (l lockPath) overlaps(p jsonpointer.Pointer) bool {
+	for i := 0; i < len(l) && i < len(p); i++ {
+		if l[i] != lockWildcard && l[i] != p[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// expand returns pointers of the values of doc which are matched by the path.
+func (l lockPath) expand(doc interface{}, prefix jsonpointer.Pointer) []jsonpointer.Pointer {
+
+	if len(l) == 0 {
+		return []jsonpointer.Pointer{prefix}
+	}
+
+	token, rest := l[0], l[1:]
+	out := []jsonpointer.Pointer{}
+
+	switch n := doc.(type) {
+	case map[string]interface{}:
+		if token != lockWildcard {
+			if next, find := n[token]; find {
+				out = append(out, rest.expand(next, prefix.Append(token))...)
+			}
+			break
+		}
+
+		keys := make([]string, 0, len(n))
+		for k := range n {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			out = append(out, rest.expand(n[k], prefix.Append(k))...)
+		}
+
+	case []interface{}:
+		for i, next := range n {
+			if token == lockWildcard || token == strconv.Itoa(i) {
+				out = append(out, rest.expand(next, prefix.Append(strconv.Itoa(i)))...)
+			}
+		}
+	}
+
+	return out
+}
+
+// LockMode tells what to do when a child tries to override a locked value.
+type LockMode int
+
+const (
+	// LockIgnore keeps the locked value silently. It's the default mode.
+	LockIgnore LockMode = iota
+	// LockWarn keeps the locked value and adds *LockedValueError to Output.Warnings.
+	LockWarn
+	// LockError stops processing with *LockedValueError.
+	LockError
+)
+
+var lockModeNames = []string{"ignore", "warn", "error"}
+
+// ParseLockMode converts "ignore", "warn" or "error" to LockMode.
+func ParseLockMode(name string) (LockMode, error) {
+	for i, n := range lockModeNames {
+		if strings.EqualFold(n, name) {
+			return LockMode(i), nil
+		}
+	}
+	return LockIgnore, fmt.Errorf("unknown lock mode '%s', it may be %s", name, lockModeNames)
+}
+
+func (l LockMode) String() string {
+	if l < 0 || int(l) >= len(lockModeNames) {
+		return strconv.Itoa(int(l))
+	}
+	return lockModeNames[l]
+}
+
+// merger applies "@parent", "@lock_names", "@merge" and "@delete".
+type merger struct {
+	lockMode LockMode
+	// context keeps sources of resolved maps and positions, it may be nil.
+	context  *Context
+	warnings []error
+	// events are provenance chains by output pointers, it may be nil.
+	events map[string][]Source
+	// merged are results of merging by their identity, it's made on demand.
+	merged map[uintptr]*mergedMap
+	// folding is set while parents are merged with each other, their values are inherited, not overridden.
+	folding bool
+}
+
+// lockLayer is "@lock_names" of the map.
+type lockLayer struct {
+	// node is the map with "@lock_names", it's the source of errors.
+	node  interface{}
+	paths []lockPath
+}
+
+// mergedMap is the result of merging. The map itself is kept, so its address can't be reused by another map.
+type mergedMap struct {
+	m map[string]interface{}
+	// locks are "@lock_names" of all maps which have been merged into it.
+	locks []lockLayer
+	// owners are the maps which have brought the values by keys, other values are of the map itself.
+	owners map[string]interface{}
+}
+
+// mergedOf returns the record of the map which is the result of merging or nil.
+func (m *merger) mergedOf(node interface{}) *mergedMap {
+	mp, ok := node.(map[string]interface{})
+	if !ok || m.merged == nil {
+		return nil
+	}
+	return m.merged[reflect.ValueOf(mp).Pointer()]
+}
+
+// setMerged saves the record of the result of merging.
+func (m *merger) setMerged(rec *mergedMap) {
+	if m.merged == nil {
+		m.merged = map[uintptr]*mergedMap{}
+	}
+	m.merged[reflect.ValueOf(rec.m).Pointer()] = rec
+}
+
+// ownerOf returns the map which has brought the value by key into node.
+func (m *merger) ownerOf(node interface{}, key string) interface{} {
+	if rec := m.mergedOf(node); rec != nil {
+		if owner, find := rec.owners[key]; find {
+			return owner
+		}
+	}
+	return node
+}
+
+// owner returns the deepest map with the known source which has brought the value by rel pointer into node
+// and the pointer of the value in that map.
+func (m *merger) owner(node interface{}, rel jsonpointer.Pointer) (interface{}, jsonpointer.Pointer) {
+	owner, at := node, 0
+	for i, key := range rel {
+		from := m.ownerOf(node, key)
+		if _, find := m.origin(from); find {
+			owner, at = from, i
+		}
+
+		mp, ok := from.(map[string]interface{})
+		if !ok {
+			break
+		}
+		node = mp[key]
+	}
+	return owner, rel[at:]
+}
+
+// lockLayers returns "@lock_names" of the map, the result of merging is locked by all merged maps.
+func (m *merger) lockLayers(node interface{}) ([]lockLayer, error) {
+	if rec := m.mergedOf(node); rec != nil {
+		return rec.locks, nil
+	}
+
+	paths, err := getLockPaths(node)
+	if err != nil {
+		return nil, m.sourceError(node, jsonpointer.Pointer{myconst.LockKeyName}, err)
+	}
+	return []lockLayer{{node: node, paths: paths}}, nil
+}
+
+// origin returns the source of the resolved map.
+func (m *merger) origin(node interface{}) (RefStep, bool) {
+	if m.context == nil {
+		return RefStep{}, false
+	}
+	return originOf(m.context.origins, node)
+}
+
+// source returns "uri#pointer" of the resolved map or empty string.
+func (m *merger) source(node interface{}) string {
+	if step, find := m.origin(node); find {
+		return step.String()
+	}
+	return ""
+}
+
+// sourceError adds the place of the value by rel pointer from the resolved map to err.
+func (m *merger) sourceError(node interface{}, rel jsonpointer.Pointer, err error) error {
+	step, find := m.origin(node)
+	if !find {
+		return err
+	}
+
+	p, _ := jsonpointer.Parse(step.Pointer)
+	step.Pointer = p.Append(rel...).String()
+	return m.context.sourceError(step, err)
+}
+
+// locked is called when the child (overriding map) tries to override the value by rel pointer
+// which is locked by the parent (locking map). path is the pointer of the overriding map.
+func (m *merger) locked(path, rel jsonpointer.Pointer, locking, overriding interface{}) error {
+	if m.lockMode == LockIgnore {
+		return nil
+	}
+
+	// The overriding map may be merged from several documents, the error points to the one with the value.
+	by, at := m.owner(overriding, rel)
+	err := m.sourceError(by, at, &LockedValueError{
+		Pointer:      path.Append(rel...).String(),
+		LockedBy:     m.source(locking),
+		OverriddenBy: m.source(by),
+	})
+
+	if m.lockMode == LockError {
+		return err
+	}
+
+	m.warnings = append(m.warnings, err)
+	return nil
+}
+
+// lockingNode returns the map on the path which locks p by "@lock_names" or nil.
+func (m *merger) lockingNode(doc interface{}, p jsonpointer.Pointer) (interface{}, error) {
+	for i := range p {
+		node, err := jsonpointer.Get(doc, p[:i])
+		if err != nil {
+			return nil, nil
+		}
+
+		layers, err := m.lockLayers(node)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, layer := range layers {
+			for _, l := range layer.paths {
+				if l.overlaps(p[i:]) {
+					return layer.node, nil
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+// deletePaths removes values from the parent, locked and missing values are left as is.
+func (m *merger) deletePaths(doc, replacement interface{}, paths []jsonpointer.Pointer, path jsonpointer.Pointer) (interface{}, error) {
+	for _, p := range paths {
+		if len(p) == 0 {
+			continue
+		}
+
+		locking, err := m.lockingNode(doc, p)
+		if err != nil {
+			return nil, err
+		}
 
-			if myconst.SchemaKeyName == key {
-				if value != nil {
-					out[key] = value
-				} else {
-					out[key] = originalValues
+		if locking != nil {
+			if jsonpointer.Has(doc, p) {
+				if err := m.locked(path, p, locking, replacement); err != nil {
+					return nil, err
 				}
+			}
+			continue
+		}
+
+		if res, err := jsonpointer.Delete(doc, p); err == nil {
+			doc = res
+		}
+	}
+	return doc, nil
+}
+
+// isDirectiveKey checks keys which are used by merging only.
+func isDirectiveKey(key string) bool {
+	return key == myconst.MergeKeyName || key == myconst.DeleteKeyName || key == myconst.UnsetKeyName
+}
+
+// Merge strategies of arrays from "@merge".
+const (
+	mergeAppend  = "append"
+	mergePrepend = "prepend"
+	mergeReplace = "replace"
+	mergeByKey   = "by-key:"
+)
+
+// getMergeStrategies extracts "@merge" of the child: key => strategy.
+func getMergeStrategies(doc interface{}) (map[string]string, error) {
+	/*
+		Exmaple:
+
+			"@merge" : {
+				"providers": "append",
+				"servers": "by-key:name"
+			}
+	*/
+
+	out := map[string]string{}
+
+	merge, find := utils.GetKeyFromInteface(doc, myconst.MergeKeyName)
+	if !find {
+		return out, nil
+	}
+
+	m, ok := merge.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("'%s' must be an object", myconst.MergeKeyName)
+	}
+
+	for key, value := range m {
+		strategy, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: strategy of '%s' must be a string", myconst.MergeKeyName, key)
+		}
+
+		switch {
+		case strategy == mergeAppend, strategy == mergePrepend, strategy == mergeReplace:
+		case strings.HasPrefix(strategy, mergeByKey) && len(strategy) > len(mergeByKey):
+		default:
+			return nil, fmt.Errorf("%s: unknown strategy '%s' of '%s'", myconst.MergeKeyName, strategy, key)
+		}
+
+		out[key] = strategy
+	}
+
+	return out, nil
+}
+
+// mergeArrays merges parent and child arrays by strategy from "@merge".
+func (m *merger) mergeArrays(original, replacement []interface{}, strategy string, path jsonpointer.Pointer) (interface{}, error) {
+
+	switch strategy {
+	case mergeAppend:
+		return append(append([]interface{}{}, original...), replacement...), nil
+
+	case mergePrepend:
+		return append(append([]interface{}{}, replacement...), original...), nil
+
+	case mergeReplace:
+		return replacement, nil
+	}
+
+	// by-key: items with the same value of the field are merged, other items are appended.
+	field := strings.TrimPrefix(strategy, mergeByKey)
+	out := append([]interface{}{}, original...)
+
+	for _, item := range replacement {
+		id, find := utils.GetKeyFromInteface(item, field)
+		if !find {
+			out = append(out, item)
+			continue
+		}
+
+		merged := false
+		for i, origItem := range out {
+			origID, origFind := utils.GetKeyFromInteface(origItem, field)
+			if !origFind || !reflect.DeepEqual(id, origID) {
 				continue
 			}
 
-			// If the values are a dictionary...
-			if originalFind && originalValues != nil && utils.IsMapStringInterface(value) {
-				// recursive call is processing viscera of structures like map[string]interface{}
-				out[key] = replaceExceptLocked(originalValues, value)
-			} else {
-				// save the value to the final response.
+			res, err := m.replaceExceptLocked(origItem, item, path.Append(strconv.Itoa(i)))
+			if err != nil {
+				return nil, err
+			}
+			out[i] = res
+			merged = true
+			break
+		}
+
+		if !merged {
+			out = append(out, item)
+		}
+	}
+
+	return out, nil
+}
+
+// restoreLocked puts back the values of the parent which are locked by deep paths.
+// The snapshot is a copy of the original map before merging.
+func (m *merger) restoreLocked(out, snapshot, replacement interface{}, locks []lockLayer, path jsonpointer.Pointer) (interface{}, error) {
+
+	for _, layer := range locks {
+		for _, l := range layer.paths {
+			for _, p := range l.expand(snapshot, nil) {
+				value, _ := jsonpointer.Get(snapshot, p)
+				if current, err := jsonpointer.Get(out, p); err == nil && reflect.DeepEqual(current, value) {
+					continue
+				}
+
+				if err := m.locked(path, p, layer.node, replacement); err != nil {
+					return nil, err
+				}
+
+				if res, err := jsonpointer.Set(out, p, value); err == nil {
+					out = res
+				}
+			}
+		}
+	}
+
+	return out, nil
+}
+
+func (m *merger) replaceExceptLocked(original, replacement interface{}, path jsonpointer.Pointer) (interface{}, error) {
+	/*
+	   Overwrites values in the original dict with those in the replacement dict,
+	   unless the key is in @lock_names.
+	   Arrays are replaced or merged by strategies from "@merge" of the replacement.
+	   Keys from "@delete" of the replacement are removed from the original before.
+	*/
+
+	if !utils.IsMapStringInterface(original) || !utils.IsMapStringInterface(replacement) {
+		return replacement, nil
+	}
+
+	// See comment to getLockPaths() function
+	layers, err := m.lockLayers(original)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keys are locked here (by the first locking map), deeper values are restored after merging.
+	lockNames := map[string]interface{}{}
+	deepLocks := []lockLayer{}
+	var snapshot interface{}
+	for _, layer := range layers {
+		deep := lockLayer{node: layer.node}
+		for _, l := range layer.paths {
+			if len(l) != 1 {
+				deep.paths = append(deep.paths, l)
+			} else if _, find := lockNames[l[0]]; !find {
+				lockNames[l[0]] = layer.node
+			}
+		}
+		if len(deep.paths) > 0 {
+			deepLocks = append(deepLocks, deep)
+		}
+	}
+	if len(deepLocks) > 0 {
+		if snapshot, err = utils.DeepCopy(original); err != nil {
+			return nil, err
+		}
+	}
+
+	strategies, err := getMergeStrategies(replacement)
+	if err != nil {
+		return nil, m.sourceError(replacement, jsonpointer.Pointer{myconst.MergeKeyName}, err)
+	}
+
+	paths, err := getDeletePaths(replacement)
+	if err != nil {
+		key := myconst.DeleteKeyName
+		if !utils.DoesIntefaceHaveKey(replacement, key) {
+			key = myconst.UnsetKeyName
+		}
+		return nil, m.sourceError(replacement, jsonpointer.Pointer{key}, err)
+	}
+
+	res, err := m.deletePaths(original, replacement, paths, path)
+	if err != nil {
+		return nil, err
+	}
+	out := res.(map[string]interface{})
+	replacementMap := replacement.(map[string]interface{})
+
+	owners := map[string]interface{}{}
+	if rec := m.mergedOf(original); rec != nil {
+		for key, owner := range rec.owners {
+			owners[key] = owner
+		}
+	}
+
+	// Values which are inherited as is.
+	for key := range out {
+		if _, find := replacementMap[key]; !find {
+			m.recordParent(path.Append(key), original, key)
+		}
+	}
+
+	// Iterate through the replacement dictionary.
+	for key, value := range replacementMap {
+		if isDirectiveKey(key) {
+			continue
+		}
+
+		originalValues, originalFind := utils.GetKeyFromInteface(original, key)
+		if originalFind {
+			m.recordParent(path.Append(key), original, key)
+		}
+
+		locking, locked := lockNames[key]
+		if !locked {
+			locking, locked = lockNames[lockWildcard]
+		}
+
+		if locked {
+			/*
+				Don't process key from "@lock_names" keys.
+				See comment to getLockNames() function
+			*/
+			if !reflect.DeepEqual(originalValues, value) {
+				if err := m.locked(path, jsonpointer.Pointer{key}, locking, replacement); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+
+		stage := ProvenanceOverride
+		if m.folding {
+			stage = ProvenanceParent
+		}
+		m.record(path.Append(key), m.sourceOf(m.ownerOf(replacement, key), key, stage)...)
+
+		if myconst.SchemaKeyName == key {
+			if value != nil {
 				out[key] = value
+				owners[key] = m.ownerOf(replacement, key)
+			} else {
+				out[key] = originalValues
 			}
+			continue
 		}
 
-		return out
+		originalList, originalIsList := originalValues.([]interface{})
+		list, isList := value.([]interface{})
+		strategy, hasStrategy := strategies[key]
+
+		switch {
+		case originalIsList && isList && hasStrategy:
+			// merge arrays by "@merge" strategy
+			out[key], err = m.mergeArrays(originalList, list, strategy, path.Append(key))
+			owners[key] = m.ownerOf(replacement, key)
+
+		// If the values are a dictionary...
+		case originalFind && originalValues != nil && utils.IsMapStringInterface(value):
+			// recursive call is processing viscera of structures like map[string]interface{}
+			out[key], err = m.replaceExceptLocked(originalValues, value, path.Append(key))
+
+		default:
+			// save the value to the final response.
+			out[key] = value
+			owners[key] = m.ownerOf(replacement, key)
+		}
+
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return replacement
+	// The result is locked by both maps, it may be merged again with the next parent or child.
+	replacementLayers, err := m.lockLayers(replacement)
+	if err != nil {
+		return nil, err
+	}
+	m.setMerged(&mergedMap{m: out, locks: append(append([]lockLayer{}, layers...), replacementLayers...), owners: owners})
+
+	if len(deepLocks) == 0 {
+		return out, nil
+	}
+
+	return m.restoreLocked(out, snapshot, replacement, deepLocks, path)
 }
 
-func mergeParents(doc interface{}, removeParentRef bool) (interface{}, error) {
-	// Merges any included parent documents with this document.
+// mergeParents merges any included parent documents with this document, path is the pointer of doc.
+func (m *merger) mergeParents(doc interface{}, path jsonpointer.Pointer) (interface{}, error) {
+
+	var err error
 
 	// Only actually try to merge if this is a dict
 	switch doc.(type) {
 	case map[string]interface{}:
 
-		m := doc.(map[string]interface{})
+		dm := doc.(map[string]interface{})
 
 		// Merge with parent(s) at this level
-		if parentsIn, find := m[myconst.ParentKeyName]; find {
-			delete(m, myconst.ParentKeyName)
+		if parentsIn, find := dm[myconst.ParentKeyName]; find {
+			delete(dm, myconst.ParentKeyName)
 
 			// Can be a single parent or list of them, so normalize to list
 			parents := utils.ToListInterface(parentsIn)
 
-			// Evaluate from left to right: first in list has last priority
-			next, err := mergeParents(parents, removeParentRef)
-			if err != nil {
-				return nil, err
+			// Parents are folded from left to right: first in list has last priority.
+			// The child is merged once, so its "@merge" and "@delete" are applied to all parents.
+			var base interface{}
+			for i, parent := range parents {
+				parent, err = m.mergeParents(parent, path)
+				if err != nil {
+					return nil, err
+				}
+
+				if i == 0 {
+					base = parent
+					continue
+				}
+
+				m.folding = true
+				base, err = m.replaceExceptLocked(base, parent, path)
+				m.folding = false
+				if err != nil {
+					return nil, err
+				}
 			}
 
-			doc = m
-			for _, parent := range utils.ReversedInterface(next) {
-				doc = replaceExceptLocked(parent, doc)
+			doc, err = m.replaceExceptLocked(base, dm, path)
+			if err != nil {
+				return nil, err
 			}
 		}
 
 		switch doc.(type) {
 		case map[string]interface{}:
-			m := doc.(map[string]interface{})
+			dm := doc.(map[string]interface{})
+
+			// "@merge" and "@delete" have been used by merging at this level.
+			for key := range dm {
+				if isDirectiveKey(key) {
+					delete(dm, key)
+				}
+			}
+
 			// Recursively check any children and merge them too
-			for key, value := range m {
-				v, err := mergeParents(value, removeParentRef)
+			for key, value := range dm {
+				dm[key], err = m.mergeParents(value, path.Append(key))
 				if err != nil {
 					return nil, err
 				}
-
-				m[key] = v
 			}
 
-			return m, nil
+			return dm, nil
 		}
 
 		return doc, nil
@@ -167,8 +803,8 @@ func mergeParents(doc interface{}, removeParentRef bool) (interface{}, error) {
 	case []interface{}:
 
 		out := []interface{}{}
-		for _, listItem := range doc.([]interface{}) {
-			res, err := mergeParents(listItem, removeParentRef)
+		for i, listItem := range doc.([]interface{}) {
+			res, err := m.mergeParents(listItem, path.Append(strconv.Itoa(i)))
 			if err != nil {
 				return nil, err
 			}
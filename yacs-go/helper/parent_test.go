@@ -3,6 +3,8 @@ package helper
 import (
 	"testing"
 
+	"github.com/iostrovok/yacs-go/yacs-go/utils"
+
 	. "gopkg.in/check.v1"
 )
 
@@ -74,6 +76,310 @@ func (s *parentTestSuite) Test_getLockNames_V04(c *C) {
 	c.Assert(res, DeepEquals, mainGetLockNamesRes)
 }
 
+func mergeTestDoc(strategy string) map[string]interface{} {
+	return map[string]interface{}{
+		"@parent": map[string]interface{}{
+			"name": "parent",
+			"servers": []interface{}{
+				map[string]interface{}{"name": "a", "port": 1.0},
+				map[string]interface{}{"name": "b", "port": 2.0},
+			},
+		},
+		"@merge": map[string]interface{}{
+			"servers": strategy,
+		},
+		"servers": []interface{}{
+			map[string]interface{}{"name": "b", "port": 3.0},
+			map[string]interface{}{"name": "c", "port": 4.0},
+		},
+	}
+}
+
+func (s *parentTestSuite) Test_mergeParents_Merge_Default(c *C) {
+	doc := mergeTestDoc("replace")
+	delete(doc, "@merge")
+
+	res, err := (&merger{}).mergeParents(doc, nil)
+	c.Assert(err, IsNil)
+	c.Assert(res, DeepEquals, map[string]interface{}{
+		"name": "parent",
+		"servers": []interface{}{
+			map[string]interface{}{"name": "b", "port": 3.0},
+			map[string]interface{}{"name": "c", "port": 4.0},
+		},
+	})
+}
+
+func (s *parentTestSuite) Test_mergeParents_Merge_Append(c *C) {
+	res, err := (&merger{}).mergeParents(mergeTestDoc("append"), nil)
+	c.Assert(err, IsNil)
+	c.Assert(res, DeepEquals, map[string]interface{}{
+		"name": "parent",
+		"servers": []interface{}{
+			map[string]interface{}{"name": "a", "port": 1.0},
+			map[string]interface{}{"name": "b", "port": 2.0},
+			map[string]interface{}{"name": "b", "port": 3.0},
+			map[string]interface{}{"name": "c", "port": 4.0},
+		},
+	})
+}
+
+func (s *parentTestSuite) Test_mergeParents_Merge_Prepend(c *C) {
+	res, err := (&merger{}).mergeParents(mergeTestDoc("prepend"), nil)
+	c.Assert(err, IsNil)
+	c.Assert(res, DeepEquals, map[string]interface{}{
+		"name": "parent",
+		"servers": []interface{}{
+			map[string]interface{}{"name": "b", "port": 3.0},
+			map[string]interface{}{"name": "c", "port": 4.0},
+			map[string]interface{}{"name": "a", "port": 1.0},
+			map[string]interface{}{"name": "b", "port": 2.0},
+		},
+	})
+}
+
+func (s *parentTestSuite) Test_mergeParents_Merge_ByKey(c *C) {
+	res, err := (&merger{}).mergeParents(mergeTestDoc("by-key:name"), nil)
+	c.Assert(err, IsNil)
+	c.Assert(res, DeepEquals, map[string]interface{}{
+		"name": "parent",
+		"servers": []interface{}{
+			map[string]interface{}{"name": "a", "port": 1.0},
+			map[string]interface{}{"name": "b", "port": 3.0},
+			map[string]interface{}{"name": "c", "port": 4.0},
+		},
+	})
+}
+
+func (s *parentTestSuite) Test_mergeParents_Merge_Nested(c *C) {
+	doc := map[string]interface{}{
+		"@parent": map[string]interface{}{
+			"app": map[string]interface{}{
+				"plugins": []interface{}{"log"},
+			},
+		},
+		"app": map[string]interface{}{
+			"@merge":  map[string]interface{}{"plugins": "append"},
+			"plugins": []interface{}{"auth"},
+		},
+	}
+
+	res, err := (&merger{}).mergeParents(doc, nil)
+	c.Assert(err, IsNil)
+	c.Assert(res, DeepEquals, map[string]interface{}{
+		"app": map[string]interface{}{
+			"plugins": []interface{}{"log", "auth"},
+		},
+	})
+}
+
+func (s *parentTestSuite) Test_mergeParents_Merge_Unknown(c *C) {
+	_, err := (&merger{}).mergeParents(mergeTestDoc("union"), nil)
+	c.Assert(err, ErrorMatches, ".*unknown strategy 'union' of 'servers'.*")
+
+	_, err = (&merger{}).mergeParents(mergeTestDoc("by-key:"), nil)
+	c.Assert(err, NotNil)
+}
+
+func deleteTestDoc(deletes interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"@parent": map[string]interface{}{
+			"@lock_names": []interface{}{"id"},
+			"id":          "parent",
+			"header":      "header",
+			"footer":      "footer",
+			"database": map[string]interface{}{
+				"@lock_names": "host",
+				"host":        "localhost",
+				"password":    "secret",
+			},
+		},
+		"@delete": deletes,
+		"title":   "child",
+	}
+}
+
+func (s *parentTestSuite) Test_mergeParents_Delete_Keys(c *C) {
+	res, err := (&merger{}).mergeParents(deleteTestDoc([]interface{}{"header", "footer", "missing"}), nil)
+	c.Assert(err, IsNil)
+	c.Assert(res, DeepEquals, map[string]interface{}{
+		"@lock_names": []interface{}{"id"},
+		"id":          "parent",
+		"title":       "child",
+		"database": map[string]interface{}{
+			"@lock_names": "host",
+			"host":        "localhost",
+			"password":    "secret",
+		},
+	})
+}
+
+func (s *parentTestSuite) Test_mergeParents_Delete_Paths(c *C) {
+	res, err := (&merger{}).mergeParents(deleteTestDoc([]interface{}{"/database/password", "/database/host", "id"}), nil)
+	c.Assert(err, IsNil)
+	c.Assert(res, DeepEquals, map[string]interface{}{
+		"@lock_names": []interface{}{"id"},
+		"id":          "parent",
+		"header":      "header",
+		"footer":      "footer",
+		"title":       "child",
+		"database": map[string]interface{}{
+			"@lock_names": "host",
+			"host":        "localhost",
+		},
+	})
+}
+
+func (s *parentTestSuite) Test_mergeParents_Unset(c *C) {
+	doc := deleteTestDoc("header")
+	doc["@unset"] = "/footer"
+
+	res, err := (&merger{}).mergeParents(doc, nil)
+	c.Assert(err, IsNil)
+	c.Assert(utils.DoesIntefaceHaveKey(res, "header"), Equals, false)
+	c.Assert(utils.DoesIntefaceHaveKey(res, "footer"), Equals, false)
+	c.Assert(utils.DoesIntefaceHaveKey(res, "@unset"), Equals, false)
+	c.Assert(utils.DoesIntefaceHaveKey(res, "@delete"), Equals, false)
+}
+
+func (s *parentTestSuite) Test_mergeParents_Delete_Error(c *C) {
+	_, err := (&merger{}).mergeParents(deleteTestDoc(1.0), nil)
+	c.Assert(err, ErrorMatches, "@delete: '1' must be a string")
+
+	_, err = (&merger{}).mergeParents(deleteTestDoc("/bad~2"), nil)
+	c.Assert(err, NotNil)
+}
+
+func (s *parentTestSuite) Test_mergeParents_Delete_Parents(c *C) {
+	doc := map[string]interface{}{
+		"@parent": []interface{}{
+			map[string]interface{}{"a": 1.0, "b": 1.0, "list": []interface{}{1.0}},
+			map[string]interface{}{"b": 2.0, "c": 3.0, "list": []interface{}{3.0}},
+		},
+		"@delete": []interface{}{"a", "c"},
+		"@merge":  map[string]interface{}{"list": "append"},
+		"list":    []interface{}{9.0},
+	}
+
+	// "@delete" and "@merge" of the child are applied to all parents.
+	res, err := (&merger{}).mergeParents(doc, nil)
+	c.Assert(err, IsNil)
+	c.Assert(res, DeepEquals, map[string]interface{}{
+		"b":    2.0,
+		"list": []interface{}{3.0, 9.0},
+	})
+}
+
+func (s *parentTestSuite) Test_mergeParents_Lock_Parents(c *C) {
+	doc := map[string]interface{}{
+		"@parent": []interface{}{
+			map[string]interface{}{"@lock_names": "x", "x": 1.0},
+			map[string]interface{}{"@lock_names": "y", "y": 2.0},
+		},
+		"x": 5.0,
+	}
+
+	// "@lock_names" of the next parent doesn't unlock values of the previous one.
+	_, err := (&merger{lockMode: LockError}).mergeParents(doc, nil)
+	c.Assert(err, ErrorMatches, "locked value /x can't be overridden")
+}
+
+func lockTestDoc() map[string]interface{} {
+	return map[string]interface{}{
+		"@parent": map[string]interface{}{
+			"@lock_names": []interface{}{"database/password", "servers.*.port"},
+			"database": map[string]interface{}{
+				"host":     "localhost",
+				"password": "secret",
+			},
+			"servers": []interface{}{
+				map[string]interface{}{"name": "a", "port": 1.0},
+				map[string]interface{}{"name": "b", "port": 2.0},
+			},
+		},
+		"database": map[string]interface{}{
+			"host":     "db",
+			"password": "new",
+		},
+		"servers": []interface{}{
+			map[string]interface{}{"name": "c", "port": 3.0},
+			map[string]interface{}{"name": "d", "port": 2.0},
+		},
+	}
+}
+
+func (s *parentTestSuite) Test_getLockPaths(c *C) {
+	doc := map[string]interface{}{
+		"@lock_names": []interface{}{"header", "/a~1b/c", "database/password", "servers.*.port"},
+	}
+
+	res, err := getLockPaths(doc)
+	c.Assert(err, IsNil)
+	c.Assert(res, DeepEquals, []lockPath{
+		{"a/b", "c"},
+		{"database", "password"},
+		{"header"},
+		{"servers", "*", "port"},
+	})
+
+	_, err = getLockPaths(map[string]interface{}{"@lock_names": "/bad~2"})
+	c.Assert(err, NotNil)
+}
+
+func (s *parentTestSuite) Test_mergeParents_DeepLock(c *C) {
+	res, err := (&merger{}).mergeParents(lockTestDoc(), nil)
+	c.Assert(err, IsNil)
+	c.Assert(res, DeepEquals, map[string]interface{}{
+		"@lock_names": []interface{}{"database/password", "servers.*.port"},
+		"database": map[string]interface{}{
+			"host":     "db",
+			"password": "secret",
+		},
+		"servers": []interface{}{
+			map[string]interface{}{"name": "c", "port": 1.0},
+			map[string]interface{}{"name": "d", "port": 2.0},
+		},
+	})
+}
+
+func (s *parentTestSuite) Test_mergeParents_DeepLock_Error(c *C) {
+	_, err := (&merger{lockMode: LockError}).mergeParents(lockTestDoc(), nil)
+	c.Assert(err, FitsTypeOf, &LockedValueError{})
+	c.Assert(err.(*LockedValueError).Pointer, Equals, "/database/password")
+
+	doc := lockTestDoc()
+	doc["database"] = map[string]interface{}{"password": "secret"}
+	_, err = (&merger{lockMode: LockError}).mergeParents(doc, nil)
+	c.Assert(err, ErrorMatches, "locked value /servers/0/port can't be overridden")
+}
+
+func (s *parentTestSuite) Test_mergeParents_Lock_Error(c *C) {
+	doc := deleteTestDoc([]interface{}{})
+	doc["id"] = "child"
+
+	_, err := (&merger{lockMode: LockError}).mergeParents(doc, nil)
+	c.Assert(err, ErrorMatches, "locked value /id can't be overridden")
+
+	// the same value isn't overriding
+	doc = deleteTestDoc([]interface{}{})
+	doc["id"] = "parent"
+	_, err = (&merger{lockMode: LockError}).mergeParents(doc, nil)
+	c.Assert(err, IsNil)
+
+	_, err = (&merger{lockMode: LockError}).mergeParents(deleteTestDoc("/database/host"), nil)
+	c.Assert(err, ErrorMatches, "locked value /database/host can't be overridden")
+}
+
+func (s *parentTestSuite) Test_mergeParents_DeepLock_Delete(c *C) {
+	doc := lockTestDoc()
+	doc["@delete"] = []interface{}{"database", "/servers/0/name"}
+	delete(doc, "database")
+
+	res, err := (&merger{}).mergeParents(doc, nil)
+	c.Assert(err, IsNil)
+	c.Assert(utils.DoesIntefaceHaveKey(res, "database"), Equals, true)
+}
+
 /*
 func (s *parentTestSuite) Test_loadFile_V02(c *C) {
 	body, err := loadFile("file://my.json")
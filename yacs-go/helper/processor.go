@@ -0,0 +1,448 @@
+package helper
+
+/*
+
+Processor is the public entry point of YACS processing.
+
+Example usage:
+
+	p := helper.NewProcessor(
+		helper.WithStages(helper.StageResolve|helper.StageInherit),
+		helper.WithBaseDir("/etc/configs"),
+		helper.WithLogger(log.New(os.Stderr, "", 0)),
+	)
+
+	res, err := p.ProcessURI("app.json")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(res.Doc)
+
+Processor is safe for concurrent use, so one object may be shared by many goroutines.
+Loaded documents are cached by CachePolicy, only the validated policies keep them between calls.
+
+*/
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/iostrovok/yacs-go/yacs-go/cache"
+	"github.com/iostrovok/yacs-go/yacs-go/format"
+	"github.com/iostrovok/yacs-go/yacs-go/loader"
+	"github.com/iostrovok/yacs-go/yacs-go/utils"
+)
+
+// Stage is a bit mask of processing steps.
+type Stage int
+
+const (
+	// StageResolve resolves "$ref" references.
+	StageResolve Stage = 1 << iota
+	// StageInherit merges "@parent" documents and applies "@lock_names".
+	StageInherit
+	// StageValidate validates documents against "@schemas".
+	StageValidate
+
+	// AllStages turns on all processing steps.
+	AllStages = StageResolve | StageInherit | StageValidate
+)
+
+var stageNames = map[Stage]string{
+	StageResolve:  "resolve",
+	StageInherit:  "inherit",
+	StageValidate: "validate",
+}
+
+func (s Stage) String() string {
+	if name, find := stageNames[s]; find {
+		return name
+	}
+	return fmt.Sprintf("Stage(%d)", int(s))
+}
+
+// Limits restricts resources which may be used by processing.
+type Limits struct {
+	// MaxDocumentSize is the max size of single loaded document in bytes. Zero means no limit.
+	// Loaders which implement loader.SizeLimiter stop reading as soon as it's exceeded.
+	MaxDocumentSize int64
+	// MaxRefDepth is the max length of chain of references. Zero means no limit.
+	MaxRefDepth int
+	// CacheEntries and CacheBytes bound the CacheLRU policy. Zero means no limit.
+	CacheEntries int
+	CacheBytes   int64
+}
+
+// CachePolicy tells how loaded documents are cached.
+type CachePolicy int
+
+const (
+	// CachePerRun keeps documents during single call of ProcessXXX. It's the default policy.
+	CachePerRun CachePolicy = iota
+	// CacheNone loads documents every time when they are referenced.
+	CacheNone
+	// CacheValidated keeps documents between calls. A document is loaded again
+	// if mtime/size of the file or ETag of the URL has been changed.
+	CacheValidated
+	// CacheLRU is CacheValidated bounded by Limits.CacheEntries and Limits.CacheBytes.
+	CacheLRU
+)
+
+// Option sets up Processor.
+type Option func(*Processor)
+
+// WithStages sets processing steps. All steps are turned on by default.
+func WithStages(stages Stage) Option {
+	return func(p *Processor) {
+		p.stages = stages
+	}
+}
+
+// WithBaseDir sets dir or URL for resolving of relative URIs of top level documents.
+func WithBaseDir(dir string) Option {
+	return func(p *Processor) {
+		p.baseDir = dir
+	}
+}
+
+// WithLoader sets loader for URI scheme. Empty scheme and "file" are local files.
+func WithLoader(scheme string, l loader.Loader) Option {
+	return func(p *Processor) {
+		p.loaders[scheme] = l
+	}
+}
+
+// WithHTTPTimeout sets timeout of requests of the default http(s) loader.
+func WithHTTPTimeout(timeout time.Duration) Option {
+	return func(p *Processor) {
+		p.httpTimeout = timeout
+	}
+}
+
+// WithHTTPHeader adds header to requests of the default http(s) loader.
+func WithHTTPHeader(key, value string) Option {
+	return func(p *Processor) {
+		p.httpHeader.Add(key, value)
+	}
+}
+
+// WithLogger sets logger for details of processing. Processor is silent by default.
+func WithLogger(logger utils.Logger) Option {
+	return func(p *Processor) {
+		p.logger = logger
+	}
+}
+
+// WithLockMode sets what to do when a child tries to override a locked value.
+func WithLockMode(mode LockMode) Option {
+	return func(p *Processor) {
+		p.lockMode = mode
+	}
+}
+
+// WithProvenance turns on building of Output.Provenance.
+func WithProvenance(on bool) Option {
+	return func(p *Processor) {
+		p.provenance = on
+	}
+}
+
+// DefaultMaxRefDepth is used if WithLimits isn't set.
+const DefaultMaxRefDepth = 64
+
+// WithLimits sets limits of processing. It replaces the default limits.
+func WithLimits(limits Limits) Option {
+	return func(p *Processor) {
+		p.limits = limits
+	}
+}
+
+// WithCachePolicy sets policy of caching of loaded documents.
+func WithCachePolicy(policy CachePolicy) Option {
+	return func(p *Processor) {
+		p.cachePolicy = policy
+		p.cache = nil
+	}
+}
+
+// WithCache sets own cache which is shared between calls. Its entries are validated like CacheValidated.
+func WithCache(c cache.Cache) Option {
+	return func(p *Processor) {
+		p.cachePolicy = CacheValidated
+		p.cache = c
+	}
+}
+
+// Processor runs YACS processing of documents.
+type Processor struct {
+	stages      Stage
+	baseDir     string
+	loaders     map[string]loader.Loader
+	logger      utils.Logger
+	limits      Limits
+	cachePolicy CachePolicy
+	httpTimeout time.Duration
+	httpHeader  http.Header
+	lockMode    LockMode
+	provenance  bool
+
+	// cache is shared between calls, it's nil for CachePerRun and CacheNone.
+	cache cache.Cache
+
+	// statsMu protects stats of finished sessions of not shared caches.
+	statsMu sync.Mutex
+	stats   cache.Stats
+}
+
+// Output is a result of processing of single document.
+type Output struct {
+	Doc interface{}
+	// Warnings are problems which don't stop processing like overriding of locked values in LockWarn mode.
+	Warnings []error
+	// Provenance is the chain of sources of each value by JSON pointer, see WithProvenance.
+	Provenance map[string][]Source
+	// Dependencies are sorted URIs of all loaded documents: the document itself, "$ref", "@parent" and "@schemas".
+	Dependencies []string
+}
+
+// NewProcessor returns Processor with all stages turned on, local file and http(s) loaders.
+func NewProcessor(opts ...Option) *Processor {
+	p := &Processor{
+		stages: AllStages,
+		limits: Limits{MaxRefDepth: DefaultMaxRefDepth},
+		loaders: map[string]loader.Loader{
+			"file": loader.FileLoader{},
+		},
+		httpTimeout: loader.DefaultHTTPTimeout,
+		httpHeader:  http.Header{},
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	httpLoader := loader.NewHTTPLoader(p.httpTimeout, p.httpHeader)
+	for _, scheme := range []string{"http", "https"} {
+		if _, find := p.loaders[scheme]; !find {
+			p.loaders[scheme] = httpLoader
+		}
+	}
+
+	if p.cache == nil {
+		switch p.cachePolicy {
+		case CacheValidated:
+			p.cache = cache.NewMap()
+		case CacheLRU:
+			p.cache = cache.NewLRU(p.limits.CacheEntries, p.limits.CacheBytes)
+		}
+	}
+
+	return p
+}
+
+// CacheStats returns hit/miss statistics of all calls.
+func (p *Processor) CacheStats() cache.Stats {
+	if p.cache != nil {
+		return p.cache.Stats()
+	}
+
+	p.statsMu.Lock()
+	defer p.statsMu.Unlock()
+	return p.stats
+}
+
+// ResetCache drops all cached documents.
+func (p *Processor) ResetCache() {
+	if p.cache != nil {
+		p.cache.Reset()
+	}
+}
+
+// ProcessURI loads the document by URI and processes it.
+func (p *Processor) ProcessURI(uri string) (*Output, error) {
+
+	context := newContext(p)
+	defer p.closeSession(context)
+
+	doc, err := getRefURI(uri, nil, context)
+	if err != nil {
+		return nil, err
+	}
+
+	return processDoc(doc, context)
+}
+
+// ProcessReader reads JSON or YAML document from r and processes it.
+// Relative references are resolved against the base dir.
+func (p *Processor) ProcessReader(r io.Reader) (*Output, error) {
+
+	body, err := loader.ReadLimited("input", r, p.limits.MaxDocumentSize)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, positions, err := decode("", "", body)
+	if err != nil {
+		return nil, err
+	}
+
+	context := newContext(p)
+	defer p.closeSession(context)
+
+	context.root = doc
+	context.positions[""] = positions
+	return processDoc(doc, context)
+}
+
+// ProcessValue processes already decoded document. The doc isn't changed.
+// Relative references are resolved against the base dir.
+func (p *Processor) ProcessValue(doc interface{}) (*Output, error) {
+
+	doc, err := utils.DeepCopy(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	context := newContext(p)
+	defer p.closeSession(context)
+
+	context.root = doc
+	return processDoc(doc, context)
+}
+
+func (p *Processor) has(stage Stage) bool {
+	return p.stages&stage == stage
+}
+
+// load reads the document, the size is checked while reading if the loader can do it.
+func (p *Processor) load(l loader.Loader, uri string) (*loader.Resource, error) {
+
+	limit := p.limits.MaxDocumentSize
+	if sl, ok := l.(loader.SizeLimiter); ok {
+		return sl.LoadLimited(uri, limit)
+	}
+
+	res, err := l.Load(uri)
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && int64(len(res.Body)) > limit {
+		return nil, &loader.TooLargeError{URI: uri, Limit: limit}
+	}
+	return res, nil
+}
+
+// sessionCache returns cache for single call of ProcessXXX.
+func (p *Processor) sessionCache() cache.Cache {
+	if p.cache != nil {
+		return p.cache
+	}
+
+	if p.cachePolicy == CacheNone {
+		return cache.NewNone()
+	}
+
+	return cache.NewMap()
+}
+
+// closeSession saves statistics of not shared cache.
+func (p *Processor) closeSession(context *Context) {
+	if context.cache == p.cache {
+		return
+	}
+
+	stats := context.cache.Stats()
+	stats.Entries, stats.Bytes = 0, 0
+
+	p.statsMu.Lock()
+	defer p.statsMu.Unlock()
+	p.stats = p.stats.Add(stats)
+}
+
+func (p *Processor) getLoader(uri string) (loader.Loader, error) {
+
+	scheme := "file"
+	if u, err := url.Parse(uri); err == nil && u.Scheme != "" {
+		scheme = u.Scheme
+	}
+
+	l, find := p.loaders[scheme]
+	if !find {
+		return nil, fmt.Errorf("%s: no loader for scheme '%s'", uri, scheme)
+	}
+
+	return l, nil
+}
+
+// isFresh returns validator of cached entries for shared cache.
+func (p *Processor) isFresh(uri string, c cache.Cache) func(cache.Entry) bool {
+	if c != p.cache {
+		// Single session sees single version of documents.
+		return nil
+	}
+
+	return func(e cache.Entry) bool {
+		l, err := p.getLoader(uri)
+		if err != nil {
+			return false
+		}
+
+		v, ok := l.(loader.Versioner)
+		if !ok {
+			return false
+		}
+
+		version, err := v.Version(uri)
+		return err == nil && version != "" && version == e.Version
+	}
+}
+
+// fetch returns parsed document by absolute URI from cache or loads it.
+func (p *Processor) fetch(uri string, c cache.Cache) (interface{}, format.Positions, error) {
+
+	if e, find := c.Get(uri, p.isFresh(uri, c)); find {
+		return e.Doc, e.Positions, nil
+	}
+
+	l, err := p.getLoader(uri)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	res, err := p.load(l, uri)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	doc, positions, err := decode(uri, res.ContentType, res.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c.Add(uri, cache.Entry{Doc: doc, Version: res.Version, Size: int64(len(res.Body)), Positions: positions})
+	return doc, positions, nil
+}
+
+// decode parses the document and finds positions of its values.
+// Errors of syntax are *SourceError.
+func decode(uri, contentType string, body []byte) (interface{}, format.Positions, error) {
+
+	f := format.Detect(uri, contentType, body)
+
+	doc, err := format.Decode(f, body)
+	if err != nil {
+		if se, ok := err.(*format.SyntaxError); ok {
+			return nil, nil, &SourceError{URI: uri, Position: se.Position, Err: se.Err}
+		}
+		return nil, nil, &SourceError{URI: uri, Err: err}
+	}
+
+	// Positions are used by errors only, so the document is fine without them.
+	positions, _ := format.Locate(f, body)
+	return doc, positions, nil
+}
@@ -0,0 +1,280 @@
+package helper
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/iostrovok/yacs-go/yacs-go/format"
+	"github.com/iostrovok/yacs-go/yacs-go/jsonschema"
+	"github.com/iostrovok/yacs-go/yacs-go/loader"
+
+	. "gopkg.in/check.v1"
+)
+
+type processorTestSuite struct{}
+
+var _ = Suite(&processorTestSuite{})
+
+var childResult = map[string]interface{}{
+	"@lock_names": []interface{}{"market-id"},
+	"market-id":   "parent",
+	"title":       "child title",
+	"database": map[string]interface{}{
+		"host": "localhost",
+		"port": float64(6432),
+	},
+}
+
+func (s *processorTestSuite) Test_ProcessURI(c *C) {
+	res, err := NewProcessor().ProcessURI("testdata/child.json")
+	c.Assert(err, IsNil)
+	c.Assert(res.Doc, DeepEquals, childResult)
+}
+
+func (s *processorTestSuite) Test_ProcessURI_BaseDir(c *C) {
+	res, err := NewProcessor(WithBaseDir("testdata")).ProcessURI("child.json")
+	c.Assert(err, IsNil)
+	c.Assert(res.Doc, DeepEquals, childResult)
+}
+
+func (s *processorTestSuite) Test_ProcessURI_Stages(c *C) {
+	res, err := NewProcessor(WithStages(StageResolve)).ProcessURI("testdata/child.json")
+	c.Assert(err, IsNil)
+
+	doc := res.Doc.(map[string]interface{})
+	c.Assert(doc["market-id"], Equals, "child")
+	c.Assert(doc["@parent"], NotNil)
+}
+
+func (s *processorTestSuite) Test_ProcessReader(c *C) {
+	r := strings.NewReader(`{"@parent": {"$ref": "parent.json"}, "title": "child title", "database": {"port": 6432}}`)
+	res, err := NewProcessor(WithBaseDir("testdata")).ProcessReader(r)
+	c.Assert(err, IsNil)
+	c.Assert(res.Doc, DeepEquals, childResult)
+}
+
+func (s *processorTestSuite) Test_ProcessValue(c *C) {
+	doc := map[string]interface{}{
+		"@parent": map[string]interface{}{"$ref": "parent.json"},
+		"title":   "child title",
+		"database": map[string]interface{}{
+			"port": float64(6432),
+		},
+	}
+
+	res, err := NewProcessor(WithBaseDir("testdata")).ProcessValue(doc)
+	c.Assert(err, IsNil)
+	c.Assert(res.Doc, DeepEquals, childResult)
+
+	// The input is not changed
+	c.Assert(doc["@parent"], NotNil)
+}
+
+func (s *processorTestSuite) Test_LockMode_Warn(c *C) {
+	res, err := NewProcessor(WithLockMode(LockWarn)).ProcessURI("testdata/child.json")
+	c.Assert(err, IsNil)
+	c.Assert(res.Doc, DeepEquals, childResult)
+	c.Assert(res.Warnings, DeepEquals, []error{
+		&SourceError{
+			URI:      "testdata/child.json",
+			Pointer:  "/market-id",
+			Position: format.Position{Line: 3, Column: 5},
+			Err: &LockedValueError{
+				Pointer:      "/market-id",
+				LockedBy:     "testdata/parent.json#",
+				OverriddenBy: "testdata/child.json#",
+			},
+		},
+	})
+
+	res, err = NewProcessor().ProcessURI("testdata/child.json")
+	c.Assert(err, IsNil)
+	c.Assert(res.Warnings, HasLen, 0)
+}
+
+func (s *processorTestSuite) Test_LockMode_Error(c *C) {
+	_, err := NewProcessor(WithLockMode(LockError)).ProcessURI("testdata/child.json")
+	c.Assert(err, ErrorMatches, "testdata/child.json:3:5: locked value /market-id \\(locked by testdata/parent.json#\\) can't be overridden by testdata/child.json#")
+}
+
+func (s *processorTestSuite) Test_LockMode_Parents(c *C) {
+	// The value is overridden by the child, not by the next parent.
+	_, err := NewProcessor(WithLockMode(LockError)).ProcessURI("testdata/parents.json")
+	c.Assert(err, ErrorMatches, "testdata/parents.json:3:5: locked value /x \\(locked by testdata/locked.json#\\) can't be overridden by testdata/parents.json#")
+
+	// The value is overridden by the child of the next parent.
+	_, err = NewProcessor(WithLockMode(LockError)).ProcessURI("testdata/parents-chain.json")
+	c.Assert(err, ErrorMatches, "testdata/override.json:3:5: locked value /x \\(locked by testdata/locked.json#\\) can't be overridden by testdata/override.json#")
+
+	res, err := NewProcessor(WithProvenance(true)).ProcessURI("testdata/parents-chain.json")
+	c.Assert(err, IsNil)
+	c.Assert(res.Provenance["/y"], DeepEquals, []Source{
+		{Stage: ProvenanceParent, URI: "testdata/plain.json", Pointer: "/y"},
+	})
+}
+
+func (s *processorTestSuite) Test_LockMode_Doc(c *C) {
+	// "@doc" of the document is stronger than the option.
+	_, err := NewProcessor(WithLockMode(LockIgnore)).ProcessURI("testdata/strict.json")
+	var lockErr *LockedValueError
+	c.Assert(errors.As(err, &lockErr), Equals, true)
+
+	_, err = ParseLockMode("strict")
+	c.Assert(err, ErrorMatches, "unknown lock mode 'strict'.*")
+
+	mode, err := ParseLockMode("WARN")
+	c.Assert(err, IsNil)
+	c.Assert(mode, Equals, LockWarn)
+}
+
+func (s *processorTestSuite) Test_Provenance(c *C) {
+	res, err := NewProcessor(WithProvenance(true)).ProcessURI("testdata/explain.json")
+	c.Assert(err, IsNil)
+
+	c.Assert(res.Provenance[""], DeepEquals, []Source{
+		{Stage: ProvenanceDoc, URI: "testdata/explain.json", Pointer: ""},
+	})
+	c.Assert(res.Provenance["/market-id"], DeepEquals, []Source{
+		{Stage: ProvenanceParent, URI: "testdata/parent.json", Pointer: "/market-id"},
+	})
+	c.Assert(res.Provenance["/title"], DeepEquals, []Source{
+		{Stage: ProvenanceParent, URI: "testdata/parent.json", Pointer: "/title"},
+		{Stage: ProvenanceOverride, URI: "testdata/explain.json", Pointer: "/title"},
+	})
+	c.Assert(res.Provenance["/database/port"], DeepEquals, []Source{
+		{Stage: ProvenanceParent, URI: "testdata/parent.json", Pointer: "/database/port"},
+	})
+	c.Assert(res.Provenance["/common-id"], DeepEquals, []Source{
+		{Stage: ProvenanceRef, URI: "testdata/common.json", Pointer: "/id"},
+		{Stage: ProvenanceOverride, URI: "testdata/explain.json", Pointer: "/common-id"},
+	})
+
+	res, err = NewProcessor().ProcessURI("testdata/explain.json")
+	c.Assert(err, IsNil)
+	c.Assert(res.Provenance, IsNil)
+}
+
+func (s *processorTestSuite) Test_Dependencies(c *C) {
+	res, err := NewProcessor().ProcessURI("testdata/explain.json")
+	c.Assert(err, IsNil)
+	c.Assert(res.Dependencies, DeepEquals, []string{
+		"testdata/common.json",
+		"testdata/explain.json",
+		"testdata/parent.json",
+	})
+
+	res, err = NewProcessor().ProcessURI("testdata/app.json")
+	c.Assert(err, IsNil)
+	c.Assert(res.Dependencies, DeepEquals, []string{
+		"testdata/app-schema.yml",
+		"testdata/app.json",
+		"testdata/base.yaml",
+		"testdata/parent.json",
+	})
+
+	res, err = NewProcessor().ProcessValue(map[string]interface{}{"a": 1.0})
+	c.Assert(err, IsNil)
+	c.Assert(res.Dependencies, DeepEquals, []string{})
+
+	// The broken parent is a dependency too, its fix changes the result.
+	dir := c.MkDir()
+	child := filepath.Join(dir, "child.json")
+	c.Assert(ioutil.WriteFile(child, []byte(`{"@parent": {"$ref": "parent.json"}}`), 0666), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "parent.json"), []byte(`{`), 0666), IsNil)
+
+	_, err = NewProcessor().ProcessURI(child)
+	c.Assert(err, NotNil)
+	c.Assert(ErrorDependencies(err), DeepEquals, []string{child, filepath.Join(dir, "parent.json")})
+
+	_, err = NewProcessor().ProcessURI(filepath.Join(dir, "missing.json"))
+	c.Assert(err, NotNil)
+	c.Assert(ErrorDependencies(err), IsNil)
+}
+
+// plainLoader can't limit reading, the size is checked after it.
+type plainLoader struct{}
+
+func (plainLoader) Load(uri string) (*loader.Resource, error) {
+	return loader.FileLoader{}.Load(uri)
+}
+
+func (s *processorTestSuite) Test_Limits(c *C) {
+	_, err := NewProcessor(WithLimits(Limits{MaxDocumentSize: 10})).ProcessURI("testdata/child.json")
+	c.Assert(err, ErrorMatches, ".*larger than 10 bytes")
+
+	var tooLarge *loader.TooLargeError
+	c.Assert(errors.As(err, &tooLarge), Equals, true)
+
+	_, err = NewProcessor(WithLimits(Limits{MaxDocumentSize: 10}), WithLoader("file", plainLoader{})).ProcessURI("testdata/child.json")
+	c.Assert(err, ErrorMatches, "testdata/child.json: document is larger than 10 bytes")
+
+	_, err = NewProcessor(WithLimits(Limits{MaxDocumentSize: 10})).ProcessReader(strings.NewReader(`{"a": "long value"}`))
+	c.Assert(err, ErrorMatches, "input: document is larger than 10 bytes")
+}
+
+func (s *processorTestSuite) Test_Validation(c *C) {
+	_, err := NewProcessor().ProcessURI("testdata/invalid.json")
+	c.Assert(err, NotNil)
+
+	var failures jsonschema.ValidationError
+	c.Assert(errors.As(err, &failures), Equals, true)
+	c.Assert(ErrorStage(err), Equals, "validate")
+	c.Assert(failures, HasLen, 1)
+	c.Assert(failures[0].SchemaKey, Equals, "user")
+	c.Assert(failures[0].Path, Equals, "/username")
+	c.Assert(failures[0].URI, Equals, "testdata/invalid.json")
+	c.Assert(failures[0].Line, Equals, 5)
+	c.Assert(failures[0].Column, Equals, 5)
+
+	res, err := NewProcessor(WithStages(StageResolve | StageInherit)).ProcessURI("testdata/invalid.json")
+	c.Assert(err, IsNil)
+	c.Assert(res.Doc, DeepEquals, map[string]interface{}{"username": float64(42)})
+}
+
+func (s *processorTestSuite) Test_SourceError(c *C) {
+	// syntax error of the file
+	_, err := NewProcessor().ProcessURI("testdata/broken.json")
+	c.Assert(err, ErrorMatches, "testdata/broken.json:4:31: invalid character '}'.*")
+
+	// missing pointer is reported at "$ref"
+	r := strings.NewReader("{\n  \"a\": 1,\n  \"b\": {\"$ref\": \"parent.json#/missing\"}\n}")
+	_, err = NewProcessor(WithBaseDir("testdata")).ProcessReader(r)
+	c.Assert(err, ErrorMatches, "3:9: .*missing.*")
+
+	var se *SourceError
+	c.Assert(errors.As(err, &se), Equals, true)
+	c.Assert(se.Pointer, Equals, "/b/$ref")
+
+	// errors of directives are reported at the directive
+	r = strings.NewReader("{\"@parent\": {\"$ref\": \"parent.json\"},\n\"@merge\": 1}")
+	_, err = NewProcessor(WithBaseDir("testdata")).ProcessReader(r)
+	c.Assert(err, ErrorMatches, "2:1: '@merge' must be an object")
+}
+
+func (s *processorTestSuite) Test_YAML(c *C) {
+	res, err := NewProcessor().ProcessURI("testdata/app.json")
+	c.Assert(err, IsNil)
+	c.Assert(res.Doc, DeepEquals, map[string]interface{}{
+		"@lock_names": []interface{}{"region"},
+		"region":      "us-east",
+		"title":       "app title",
+		"database": map[string]interface{}{
+			"host":     "localhost",
+			"port":     float64(5432),
+			"defaults": map[string]interface{}{"host": "localhost", "port": float64(5432)},
+		},
+		"raw": map[string]interface{}{
+			"@doc": map[string]interface{}{"resolve": false},
+			"keep": map[string]interface{}{"$ref": "missing.yaml"},
+		},
+	})
+}
+
+func (s *processorTestSuite) Test_ProcessReader_YAML(c *C) {
+	r := strings.NewReader("\"@parent\":\n  $ref: parent.json\ntitle: child title\ndatabase:\n  port: 6432\n")
+	res, err := NewProcessor(WithBaseDir("testdata")).ProcessReader(r)
+	c.Assert(err, IsNil)
+	c.Assert(res.Doc, DeepEquals, childResult)
+}
@@ -0,0 +1,165 @@
+package helper
+
+/*
+
+Provenance tells where each value of the result comes from.
+
+Example usage:
+
+	p := helper.NewProcessor(helper.WithProvenance(true))
+	res, err := p.ProcessURI("child.json")
+	...
+	for _, src := range res.Provenance["/market-id"] {
+		fmt.Println(src)
+	}
+
+prints the chain in the order of processing, the last source sets the value:
+
+	ref common.json#/id
+	parent parent.json#/market-id
+	override child.json#/market-id
+
+Items of arrays which are merged by "@merge" are attributed to the array.
+
+*/
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/iostrovok/yacs-go/yacs-go/jsonpointer"
+)
+
+// ProvenanceStage tells how the value got to the result.
+type ProvenanceStage string
+
+const (
+	// ProvenanceDoc is the value of the document itself.
+	ProvenanceDoc ProvenanceStage = "doc"
+	// ProvenanceRef is the value which is loaded by "$ref".
+	ProvenanceRef ProvenanceStage = "ref"
+	// ProvenanceParent is the value which is inherited from "@parent".
+	ProvenanceParent ProvenanceStage = "parent"
+	// ProvenanceOverride is the value which is set by the document over its "@parent".
+	ProvenanceOverride ProvenanceStage = "override"
+)
+
+// Source is a single step of the provenance chain.
+type Source struct {
+	Stage   ProvenanceStage
+	URI     string
+	Pointer string
+}
+
+func (s Source) String() string {
+	return string(s.Stage) + " " + s.URI + "#" + s.Pointer
+}
+
+// child returns the source of the value by key.
+func (s Source) child(key string) Source {
+	s.Pointer += "/" + jsonpointer.Escape(key)
+	return s
+}
+
+// record adds sources to the chain of the value by output pointer.
+// The source which is the last one already is skipped, folded parents may bring it again.
+func (m *merger) record(p jsonpointer.Pointer, sources ...Source) {
+	if m.events == nil {
+		return
+	}
+	key := p.String()
+	for _, src := range sources {
+		if chain := m.events[key]; len(chain) > 0 && chain[len(chain)-1] == src {
+			continue
+		}
+		m.events[key] = append(m.events[key], src)
+	}
+}
+
+// recorded checks that the value has the chain already.
+func (m *merger) recorded(p jsonpointer.Pointer) bool {
+	_, find := m.events[p.String()]
+	return find
+}
+
+// sourceOf returns the source of the value by key of the resolved map.
+func (m *merger) sourceOf(node interface{}, key string, stage ProvenanceStage) []Source {
+	step, find := m.origin(node)
+	if !find {
+		return nil
+	}
+	return []Source{Source{Stage: stage, URI: step.URI, Pointer: step.Pointer}.child(key)}
+}
+
+// recordParent adds the inherited value if it has no chain yet.
+func (m *merger) recordParent(p jsonpointer.Pointer, original interface{}, key string) {
+	if m.events != nil && !m.recorded(p) {
+		m.record(p, m.sourceOf(m.ownerOf(original, key), key, ProvenanceParent)...)
+	}
+}
+
+// buildProvenance returns chains of all values of doc by JSON pointers.
+// Values without own events get the chain of the container.
+func buildProvenance(doc interface{}, root Source, events map[string][]Source, refs map[RefStep]RefStep) map[string][]Source {
+	out := map[string][]Source{}
+	walkProvenance(doc, nil, []Source{root}, events, refs, out)
+	return out
+}
+
+func walkProvenance(node interface{}, p jsonpointer.Pointer, chain []Source, events map[string][]Source, refs map[RefStep]RefStep, out map[string][]Source) {
+
+	if ev, find := events[p.String()]; find && len(ev) > 0 {
+		chain = ev
+	}
+	chain = expandRefs(chain, refs)
+	out[p.String()] = chain
+
+	child := func(key string) []Source {
+		res := make([]Source, len(chain))
+		for i, s := range chain {
+			res[i] = s.child(key)
+		}
+		return res
+	}
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(n))
+		for k := range n {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			walkProvenance(n[k], p.Append(k), child(k), events, refs, out)
+		}
+
+	case []interface{}:
+		for i, v := range n {
+			k := strconv.Itoa(i)
+			walkProvenance(v, p.Append(k), child(k), events, refs, out)
+		}
+	}
+}
+
+// expandRefs adds the targets of "$ref" before each source which is a reference.
+func expandRefs(chain []Source, refs map[RefStep]RefStep) []Source {
+
+	out := make([]Source, 0, len(chain))
+	for _, s := range chain {
+		targets := []Source{}
+		at := RefStep{URI: s.URI, Pointer: s.Pointer}
+		for len(targets) < len(refs) {
+			target, find := refs[at]
+			if !find {
+				break
+			}
+			targets = append([]Source{{Stage: ProvenanceRef, URI: target.URI, Pointer: target.Pointer}}, targets...)
+			at = target
+		}
+		out = append(out, targets...)
+		out = append(out, s)
+	}
+
+	return out
+}
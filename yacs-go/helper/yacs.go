@@ -1,6 +1,9 @@
 package helper
 
 import (
+	"strconv"
+
+	"github.com/iostrovok/yacs-go/yacs-go/jsonpointer"
 	"github.com/iostrovok/yacs-go/yacs-go/jsonschema"
 	"github.com/iostrovok/yacs-go/yacs-go/myconst"
 	"github.com/iostrovok/yacs-go/yacs-go/utils"
@@ -24,10 +27,14 @@ func notNeedResolv(doc interface{}) bool {
 	return false
 }
 
-func resolveDoc(doc interface{}, context *Context) (interface{}, error) {
+// resolveDoc resolves references of doc, ptr is the pointer of doc in the current file.
+func resolveDoc(doc interface{}, context *Context, ptr jsonpointer.Pointer) (interface{}, error) {
 
 	// Don't process this doc if resolve is turned off via directive
 	if notNeedResolv(doc) {
+		if m, ok := doc.(map[string]interface{}); ok {
+			context.setOrigin(m, ptr)
+		}
 		return doc, nil
 	}
 
@@ -35,62 +42,55 @@ func resolveDoc(doc interface{}, context *Context) (interface{}, error) {
 	if utils.IsMapStringInterface(doc) {
 		if isJSONRef(doc) {
 			// Look for JSON References in all pieces of this document.
-			return resolveRefInDoc(doc, context)
+			return resolveRefInDoc(doc, context, ptr)
 		}
-		return resolveMapDoc(doc.(map[string]interface{}), context)
+		return resolveMapDoc(doc.(map[string]interface{}), context, ptr)
 	}
 
 	if utils.IsListInterface(doc) {
-		return resolveArrayDoc(doc.([]interface{}), context)
+		return resolveArrayDoc(doc.([]interface{}), context, ptr)
 	}
 
 	return doc, nil
 }
 
-func resolveRefInDoc(docIn interface{}, context *Context) (interface{}, error) {
+func resolveRefInDoc(docIn interface{}, context *Context, ptr jsonpointer.Pointer) (interface{}, error) {
 
 	uri, err := extractJSONrefURI(docIn)
 	if err != nil {
 		return nil, err
 	}
 
-	absuri := context.getDir(uri)
-	if out, find := uriCache.Get(absuri); find {
-		return out, nil
-	}
-
 	// Context will be getting updated so make a copy.
 	docContext := context.copy()
 	doc, err := getRefURI(uri, docIn, docContext)
 	if err != nil {
-		return nil, err
+		return nil, context.sourceError(RefStep{URI: context.uri, Pointer: ptr.Append(myconst.JSONRefKeyName).String()}, err)
 	}
 
-	out, err := resolveDoc(doc, docContext)
-	if err != nil {
-		return nil, err
-	}
+	target := docContext.pointer()
+	context.refs[RefStep{URI: context.uri, Pointer: ptr.String()}] = RefStep{URI: docContext.uri, Pointer: target.String()}
 
-	uriCache.Add(absuri, out)
-	return out, nil
+	return resolveDoc(doc, docContext, target)
 }
 
-func resolveMapDoc(doc map[string]interface{}, context *Context) (interface{}, error) {
+func resolveMapDoc(doc map[string]interface{}, context *Context, ptr jsonpointer.Pointer) (interface{}, error) {
 	out := map[string]interface{}{}
 	for key, value := range doc {
-		res, err := resolveDoc(value, context)
+		res, err := resolveDoc(value, context, ptr.Append(key))
 		if err != nil {
 			return nil, err
 		}
 		out[key] = res
 	}
+	context.setOrigin(out, ptr)
 	return out, nil
 }
 
-func resolveArrayDoc(doc []interface{}, context *Context) (interface{}, error) {
+func resolveArrayDoc(doc []interface{}, context *Context, ptr jsonpointer.Pointer) (interface{}, error) {
 	out := []interface{}{}
-	for _, value := range doc {
-		res, err := resolveDoc(value, context)
+	for i, value := range doc {
+		res, err := resolveDoc(value, context, ptr.Append(strconv.Itoa(i)))
 		if err != nil {
 			return nil, err
 		}
@@ -99,45 +99,88 @@ func resolveArrayDoc(doc []interface{}, context *Context) (interface{}, error) {
 	return out, nil
 }
 
-func processDoc(doc interface{}, context *Context, needToResolve, needToInherit, validateJSONSchema, verbose bool) (interface{}, error) {
+// docLockMode returns the mode from "@doc": {"locks": "warn"} of the document or the default mode.
+func docLockMode(doc interface{}, mode LockMode) (LockMode, error) {
+	directives, _ := utils.GetKeyFromInteface(doc, myconst.DocKeyName)
+	name, find := utils.GetKeyFromIntefaceString(directives, myconst.LocksKeyName)
+	if !find {
+		return mode, nil
+	}
+	return ParseLockMode(name)
+}
+
+func processDoc(doc interface{}, context *Context) (*Output, error) {
 
 	var err error
 	processed := doc
+	p := context.proc
+	out := &Output{}
+	events := map[string][]Source{}
 
 	// Resolve References
-	if needToResolve {
-		processed, err = resolveDoc(processed, context)
+	if p.has(StageResolve) {
+		processed, err = resolveDoc(processed, context, context.pointer())
 		if err != nil {
-			return nil, err
+			return nil, &StageError{Stage: StageResolve, Err: err, Dependencies: context.dependencies()}
 		}
 	}
 
 	// Apply Inheritance/locking
-	if needToInherit {
-		processed, err = mergeParents(processed, true)
+	if p.has(StageInherit) {
+		mode, err := docLockMode(doc, p.lockMode)
 		if err != nil {
-			return nil, err
+			return nil, &StageError{Stage: StageInherit, Err: err, Dependencies: context.dependencies()}
+		}
+
+		m := &merger{lockMode: mode, context: context, events: events}
+
+		processed, err = m.mergeParents(processed, nil)
+		if err != nil {
+			return nil, &StageError{Stage: StageInherit, Err: err, Dependencies: context.dependencies()}
 		}
+		out.Warnings = m.warnings
 	}
 
+	root := Source{Stage: ProvenanceDoc, URI: context.uri, Pointer: context.pointer().String()}
+
 	// Validate schema if possible
-	if !validateJSONSchema {
-		return jsonschema.RemoveSchemaReferences(processed), nil
+	if !p.has(StageValidate) {
+		out.Doc = jsonschema.RemoveSchemaReferences(processed)
+	} else if out.Doc, err = jsonschema.ValidateSchema(processed, p.logger); err != nil {
+		if failures, ok := err.(jsonschema.ValidationError); ok {
+			locateFailures(failures, buildProvenance(out.Doc, root, events, context.refs), context)
+		}
+		return nil, &StageError{Stage: StageValidate, Err: err, Dependencies: context.dependencies()}
+	}
+
+	if p.provenance {
+		out.Provenance = buildProvenance(out.Doc, root, events, context.refs)
 	}
+	out.Dependencies = context.dependencies()
 
-	return jsonschema.ValidateSchema(processed, verbose)
+	return out, nil
 }
 
-// Process is main function - it starts processing single files by URI
-func Process(uri string, needToResolve, needToInherit, validateJSONSchema, verbose bool) (interface{}, error) {
+// locateFailures sets the places in the sources of invalid values.
+func locateFailures(failures jsonschema.ValidationError, provenance map[string][]Source, context *Context) {
+	for i, f := range failures {
+		p, err := jsonpointer.Parse(f.Path)
+		if err != nil {
+			continue
+		}
 
-	context := newContext()
+		chain, find := provenance[p.String()]
+		for !find && len(p) > 0 {
+			p = p.Parent()
+			chain, find = provenance[p.String()]
+		}
+		if !find || len(chain) == 0 {
+			continue
+		}
 
-	doc, err := getRefURI(uri, nil, context)
-	if err != nil {
-		return nil, err
+		// The last source sets the value.
+		src := chain[len(chain)-1]
+		pos, _ := context.positions[src.URI].Find(src.Pointer)
+		failures[i].URI, failures[i].Line, failures[i].Column = src.URI, pos.Line, pos.Column
 	}
-
-	// Resolve references + inherit
-	return processDoc(doc, context, needToResolve, needToInherit, validateJSONSchema, verbose)
 }
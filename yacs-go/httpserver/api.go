@@ -0,0 +1,331 @@
+package httpserver
+
+/*
+
+API of processed configs.
+
+	GET  /config/<path>[?format=yaml]   returns the processed document <path> of the root dir with ETag, see etag.go
+	GET  /watch/<path>[?format=yaml]    tells about changes of the document, see watch.go
+	POST /process[?format=yaml]         returns the processed document from the body, references are relative to the root dir
+
+The result is JSON by default, "format" may be any output format of the format package.
+Errors are JSON objects:
+
+	{
+		"error": {
+			"status": 422,
+			"code": "invalid_document",
+			"message": "the document is not valid, 1 error(s): ...",
+			"stage": "validate",
+			"failures": [{"schema": "app", "path": "/port", "description": "...", "uri": "app.json", "line": 3, "column": 5}]
+		}
+	}
+
+*/
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/iostrovok/yacs-go/yacs-go/format"
+	"github.com/iostrovok/yacs-go/yacs-go/helper"
+	"github.com/iostrovok/yacs-go/yacs-go/jsonschema"
+)
+
+// MaxBodySize is the max size of the document of POST /process.
+const MaxBodySize = 10 << 20
+
+// Codes of errors of API.
+const (
+	CodeBadRequest       = "bad_request"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeTooLarge         = "too_large"
+	CodeConflict         = "conflict"
+	CodeTooManyRequests  = "too_many_requests"
+	CodeInvalidDocument  = "invalid_document"
+	CodeInternal         = "internal"
+)
+
+var contentTypes = map[format.Format]string{
+	format.JSON:        "application/json",
+	format.JSONCompact: "application/json",
+	format.YAML:        "application/yaml",
+	format.TOML:        "application/toml",
+	format.Env:         "text/plain; charset=utf-8",
+	format.Properties:  "text/plain; charset=utf-8",
+}
+
+// APIFailure is a single violation of a schema.
+type APIFailure struct {
+	Schema      string `json:"schema"`
+	Pointer     string `json:"pointer"`
+	Path        string `json:"path"`
+	Field       string `json:"field,omitempty"`
+	Type        string `json:"type,omitempty"`
+	Description string `json:"description"`
+	URI         string `json:"uri,omitempty"`
+	Line        int    `json:"line,omitempty"`
+	Column      int    `json:"column,omitempty"`
+}
+
+// APIError is the body of error responses: {"error": {...}}.
+type APIError struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Stage is "load", "resolve", "inherit", "validate" or "encode" for errors of processing.
+	Stage string `json:"stage,omitempty"`
+	// URI, Line and Column are the place of the problem in the source if it's known.
+	URI      string       `json:"uri,omitempty"`
+	Line     int          `json:"line,omitempty"`
+	Column   int          `json:"column,omitempty"`
+	Failures []APIFailure `json:"failures,omitempty"`
+}
+
+func writeError(w http.ResponseWriter, e *APIError) {
+	writeJSON(w, e.Status, map[string]*APIError{"error": e})
+}
+
+func writeJSON(w http.ResponseWriter, status int, what interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	jsonWrite(w, what)
+}
+
+func newError(status int, code string, err error) *APIError {
+	return &APIError{Status: status, Code: code, Message: err.Error()}
+}
+
+// processingError converts errors of helper.Processor, URIs are relative to the root dir.
+func processingError(err error, root string, status int) *APIError {
+
+	e := newError(status, CodeInvalidDocument, err)
+	e.Stage = helper.ErrorStage(err)
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		e.Status, e.Code, e.Stage = http.StatusRequestEntityTooLarge, CodeTooLarge, ""
+	}
+
+	var se *helper.SourceError
+	if errors.As(err, &se) {
+		e.URI, e.Line, e.Column = relative(root, se.URI), se.Line, se.Column
+	}
+
+	var failures jsonschema.ValidationError
+	if errors.As(err, &failures) {
+		for _, f := range failures {
+			e.Failures = append(e.Failures, APIFailure{
+				Schema:      f.SchemaKey,
+				Pointer:     f.Pointer,
+				Path:        f.Path,
+				Field:       f.Field,
+				Type:        f.Type,
+				Description: f.Description,
+				URI:         relative(root, f.URI),
+				Line:        f.Line,
+				Column:      f.Column,
+			})
+		}
+	}
+
+	e.Message = strings.Replace(e.Message, filepath.Clean(root)+string(filepath.Separator), "", -1)
+	return e
+}
+
+// relative hides the root dir of the server.
+func relative(root, uri string) string {
+	if rel, err := filepath.Rel(root, uri); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return uri
+}
+
+// outputFormat returns the format from "format" query parameter.
+func outputFormat(r *http.Request) (format.Format, *APIError) {
+	name := r.URL.Query().Get("format")
+	if name == "" {
+		return format.JSON, nil
+	}
+
+	f, err := format.Parse(name)
+	if err != nil {
+		return "", newError(http.StatusBadRequest, CodeBadRequest, err)
+	}
+	return f, nil
+}
+
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, m := range methods {
+		if r.Method == m {
+			return true
+		}
+	}
+
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, &APIError{
+		Status:  http.StatusMethodNotAllowed,
+		Code:    CodeMethodNotAllowed,
+		Message: "method " + r.Method + " is not allowed, use " + strings.Join(methods, " or "),
+	})
+	return false
+}
+
+// encodeResult encodes the document by the format.
+func encodeResult(f format.Format, res *helper.Output) ([]byte, *APIError) {
+	body, err := format.Encode(f, res.Doc)
+	if err != nil {
+		e := newError(http.StatusUnprocessableEntity, CodeInvalidDocument, err)
+		e.Stage = "encode"
+		return nil, e
+	}
+	return body, nil
+}
+
+// writeResult encodes the document by the format from the query.
+func writeResult(w http.ResponseWriter, r *http.Request, res *helper.Output) {
+
+	f, apiErr := outputFormat(r)
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+
+	body, apiErr := encodeResult(f, res)
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypes[f])
+	w.Write(body)
+}
+
+// configFile returns the path of the document relative to the root dir, the path never leaves the root dir.
+func configFile(prefix, urlPath string) string {
+	rel := path.Clean("/" + strings.TrimPrefix(urlPath, prefix))
+	return strings.TrimPrefix(rel, "/")
+}
+
+// findConfig returns the format and the path of the config from the URL, it writes the error if they are wrong.
+func findConfig(w http.ResponseWriter, r *http.Request, sets *settings, prefix string) (format.Format, string, bool) {
+
+	f, apiErr := outputFormat(r)
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return "", "", false
+	}
+
+	rel := configFile(prefix, r.URL.Path)
+	if info, err := os.Stat(filepath.Join(sets.Dir, filepath.FromSlash(rel))); rel == "" || err != nil || info.IsDir() {
+		writeError(w, &APIError{Status: http.StatusNotFound, Code: CodeNotFound, Message: "config '" + rel + "' is not found"})
+		return "", "", false
+	}
+
+	return f, rel, true
+}
+
+// loadConfig processes and encodes the config, its ETag is stored for next requests.
+func loadConfig(sets *settings, f format.Format, rel string) (*helper.Output, []byte, string, *APIError) {
+
+	key := tagKey(f, rel)
+
+	res, err := sets.processor.ProcessURI(filepath.FromSlash(rel))
+	if err != nil {
+		sets.tags.drop(key)
+		return nil, nil, "", processingError(err, sets.Dir, http.StatusUnprocessableEntity)
+	}
+
+	body, apiErr := encodeResult(f, res)
+	if apiErr != nil {
+		sets.tags.drop(key)
+		return nil, nil, "", apiErr
+	}
+
+	etag := makeETag(body)
+	sets.tags.store(key, etag, res.Dependencies)
+	return res, body, etag, nil
+}
+
+func handlerConfig(w http.ResponseWriter, r *http.Request) {
+
+	sets, err := getContextHelper(r)
+	if err != nil {
+		writeError(w, newError(http.StatusInternalServerError, CodeInternal, err))
+		return
+	}
+
+	if !allowMethods(w, r, http.MethodGet, http.MethodHead) {
+		return
+	}
+
+	f, rel, ok := findConfig(w, r, sets, "/config/")
+	if !ok {
+		return
+	}
+
+	// Clients must check the config every time, unchanged configs cost 304 only.
+	w.Header().Set("Cache-Control", "no-cache")
+
+	if etag, ok := sets.tags.lookup(tagKey(f, rel)); ok && notModified(r, etag) {
+		writeNotModified(w, etag)
+		return
+	}
+
+	_, body, etag, apiErr := loadConfig(sets, f, rel)
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+
+	if notModified(r, etag) {
+		writeNotModified(w, etag)
+		return
+	}
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Content-Type", contentTypes[f])
+	w.Write(body)
+}
+
+func handlerProcess(w http.ResponseWriter, r *http.Request) {
+
+	sets, err := getContextHelper(r)
+	if err != nil {
+		writeError(w, newError(http.StatusInternalServerError, CodeInternal, err))
+		return
+	}
+
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+
+	if _, apiErr := outputFormat(r); apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+
+	var body io.Reader = http.MaxBytesReader(w, r.Body, MaxBodySize)
+	res, err := sets.processor.ProcessReader(body)
+	if err != nil {
+		status := http.StatusUnprocessableEntity
+		if helper.ErrorStage(err) == "load" {
+			// The body itself is broken.
+			status = http.StatusBadRequest
+		}
+		e := processingError(err, sets.Dir, status)
+		if e.Status == http.StatusBadRequest {
+			e.Code = CodeBadRequest
+		}
+		writeError(w, e)
+		return
+	}
+
+	writeResult(w, r, res)
+}
@@ -0,0 +1,189 @@
+package httpserver
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Authenticator checks credentials of requests.
+type Authenticator interface {
+	// Authenticate returns true if the request may be served.
+	Authenticate(r *http.Request) bool
+	// Challenge is the value of WWW-Authenticate header of 401 responses.
+	Challenge() string
+}
+
+// BasicAuth checks "Authorization: Basic ..." by users and their password hashes.
+type BasicAuth struct {
+	users map[string]string
+}
+
+// NewBasicAuth returns authenticator with single user and plain password, e.g. from the environment.
+func NewBasicAuth(username, password string) *BasicAuth {
+	return &BasicAuth{users: map[string]string{username: "{PLAIN}" + password}}
+}
+
+// LoadHtpasswd reads users of htpasswd file. Bcrypt ($2y$) and SHA1 ({SHA}) hashes are supported,
+// other schemes are errors, so no hash is ever compared as a plain password.
+func LoadHtpasswd(file string) (*BasicAuth, error) {
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	a := &BasicAuth{users: map[string]string{}}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		kv := strings.SplitN(text, ":", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("%s:%d: line must be 'user:hash'", file, line)
+		}
+		switch {
+		case strings.HasPrefix(kv[1], "$apr1$"):
+			return nil, fmt.Errorf("%s:%d: MD5 (apr1) hashes are not supported, use bcrypt: htpasswd -B", file, line)
+		case !strings.HasPrefix(kv[1], "$2") && !strings.HasPrefix(kv[1], "{SHA}"):
+			return nil, fmt.Errorf("%s:%d: unknown hash scheme, use bcrypt: htpasswd -B", file, line)
+		}
+		a.users[kv[0]] = kv[1]
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(a.users) == 0 {
+		return nil, fmt.Errorf("%s: no users", file)
+	}
+
+	return a, nil
+}
+
+// Authenticate checks the user and the password. Empty passwords are never accepted.
+func (a *BasicAuth) Authenticate(r *http.Request) bool {
+	user, pass, ok := r.BasicAuth()
+	if !ok || pass == "" {
+		return false
+	}
+
+	hash, find := a.users[user]
+	if !find {
+		return false
+	}
+
+	return checkPassword(hash, pass)
+}
+
+// Challenge asks for basic credentials.
+func (a *BasicAuth) Challenge() string {
+	return `Basic realm="YACS"`
+}
+
+// checkPassword compares the password with the hash of known scheme, unknown schemes never match.
+func checkPassword(hash, pass string) bool {
+	switch {
+	case strings.HasPrefix(hash, "$2"):
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(pass)) == nil
+	case strings.HasPrefix(hash, "{SHA}"):
+		sum := sha1.Sum([]byte(pass))
+		return subtle.ConstantTimeCompare([]byte(hash[5:]), []byte(base64.StdEncoding.EncodeToString(sum[:]))) == 1
+	case strings.HasPrefix(hash, "{PLAIN}"):
+		return subtle.ConstantTimeCompare([]byte(hash[7:]), []byte(pass)) == 1
+	}
+	return false
+}
+
+// TokenAuth checks "Authorization: Bearer <token>" by the list of tokens.
+type TokenAuth struct {
+	tokens []string
+}
+
+// NewTokenAuth returns authenticator of tokens, empty tokens are skipped.
+func NewTokenAuth(tokens ...string) *TokenAuth {
+	a := &TokenAuth{}
+	for _, t := range tokens {
+		if t = strings.TrimSpace(t); t != "" {
+			a.tokens = append(a.tokens, t)
+		}
+	}
+	return a
+}
+
+// LoadTokens reads tokens from file, one token by line. Lines which start with "#" are skipped.
+func LoadTokens(file string) (*TokenAuth, error) {
+	body, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := []string{}
+	for _, line := range strings.Split(string(body), "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "#") {
+			tokens = append(tokens, line)
+		}
+	}
+
+	a := NewTokenAuth(tokens...)
+	if len(a.tokens) == 0 {
+		return nil, fmt.Errorf("%s: no tokens", file)
+	}
+	return a, nil
+}
+
+// Authenticate checks the token of the request.
+func (a *TokenAuth) Authenticate(r *http.Request) bool {
+	h := r.Header.Get("Authorization")
+	if len(h) < 7 || !strings.EqualFold(h[:7], "Bearer ") {
+		return false
+	}
+
+	token := []byte(strings.TrimSpace(h[7:]))
+	ok := false
+	for _, t := range a.tokens {
+		// All tokens are compared, so the time doesn't tell which one is close.
+		if subtle.ConstantTimeCompare(token, []byte(t)) == 1 {
+			ok = true
+		}
+	}
+	return ok
+}
+
+// Challenge asks for a bearer token.
+func (a *TokenAuth) Challenge() string {
+	return `Bearer realm="YACS"`
+}
+
+// AnyAuth passes requests which are passed by any of authenticators.
+type AnyAuth []Authenticator
+
+// Authenticate checks the request by all authenticators.
+func (list AnyAuth) Authenticate(r *http.Request) bool {
+	for _, a := range list {
+		if a.Authenticate(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// Challenge returns the challenge of the first authenticator.
+func (list AnyAuth) Challenge() string {
+	if len(list) == 0 {
+		return ""
+	}
+	return list[0].Challenge()
+}
@@ -0,0 +1,124 @@
+package httpserver
+
+/*
+
+ETags of configs.
+
+	GET /config/app.json
+	200 OK
+	ETag: "6f1ed002ab5595859014ebf0951522d9"
+
+	GET /config/app.json
+	If-None-Match: "6f1ed002ab5595859014ebf0951522d9"
+	304 Not Modified
+
+The ETag is the hash of the encoded result, so it depends on the format too.
+The server keeps the ETag with hashes of all dependencies of the config: the config itself,
+"$ref", "@parent" and "@schemas" documents. While none of them is changed, 304 is returned
+without processing. Configs with remote dependencies are processed every time.
+
+*/
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/iostrovok/yacs-go/yacs-go/batch"
+	"github.com/iostrovok/yacs-go/yacs-go/format"
+)
+
+type tagEntry struct {
+	etag string
+	// deps are fingerprints of dependencies by URIs.
+	deps map[string]string
+}
+
+// tagCache keeps ETags of configs by format and path.
+type tagCache struct {
+	mu      sync.Mutex
+	entries map[string]*tagEntry
+}
+
+// tagKey is the key of the config in the format.
+func tagKey(f format.Format, rel string) string {
+	return string(f) + ":" + rel
+}
+
+func newTagCache() *tagCache {
+	return &tagCache{entries: map[string]*tagEntry{}}
+}
+
+// lookup returns the ETag of the config if no dependency has been changed since it's stored.
+func (c *tagCache) lookup(key string) (string, bool) {
+
+	c.mu.Lock()
+	e, find := c.entries[key]
+	c.mu.Unlock()
+
+	if !find {
+		return "", false
+	}
+
+	for uri, fp := range e.deps {
+		// Hashes are used instead of mtime, a file may be changed twice in the same tick.
+		if now, ok := batch.Fingerprint(uri, batch.CheckHash); !ok || now != fp {
+			return "", false
+		}
+	}
+	return e.etag, true
+}
+
+// store saves the ETag with fingerprints of dependencies. Nothing is saved if any of them can't be checked.
+func (c *tagCache) store(key, etag string, deps []string) {
+
+	e := &tagEntry{etag: etag, deps: map[string]string{}}
+	for _, uri := range deps {
+		fp, ok := batch.Fingerprint(uri, batch.CheckHash)
+		if !ok {
+			c.drop(key)
+			return
+		}
+		e.deps[uri] = fp
+	}
+
+	c.mu.Lock()
+	c.entries[key] = e
+	c.mu.Unlock()
+}
+
+func (c *tagCache) drop(key string) {
+	c.mu.Lock()
+	delete(c.entries, key)
+	c.mu.Unlock()
+}
+
+// makeETag returns the strong ETag of the body.
+func makeETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// notModified checks If-None-Match of the request, weak tags are compared like strong ones.
+func notModified(r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeNotModified answers 304 with the ETag, it has no body.
+func writeNotModified(w http.ResponseWriter, etag string) {
+	w.Header().Set("ETag", etag)
+	w.WriteHeader(http.StatusNotModified)
+}
@@ -0,0 +1,95 @@
+package httpserver_test
+
+import (
+	"net/http"
+
+	"github.com/iostrovok/yacs-go/yacs-go/httpserver"
+
+	. "gopkg.in/check.v1"
+)
+
+type etagTestSuite struct {
+	rootSuite
+}
+
+var _ = Suite(&etagTestSuite{})
+
+func (s *etagTestSuite) SetUpTest(c *C) {
+	s.serve(c, httpserver.Config{})
+
+	s.write(c, "parent.json", `{"port": 8080}`)
+	s.write(c, "common.json", `{"level": "info"}`)
+	s.write(c, "app.json", `{"@parent": {"$ref": "parent.json"}, "log": {"$ref": "common.json"}}`)
+}
+
+func (s *etagTestSuite) get(c *C, path, etag string) (int, string) {
+	header := map[string]string{}
+	if etag != "" {
+		header["If-None-Match"] = etag
+	}
+
+	resp := s.request(c, "GET", path, "", header)
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified || resp.StatusCode == http.StatusOK {
+		c.Assert(resp.Header.Get("ETag"), Matches, `"[0-9a-f]{32}"`)
+		c.Assert(resp.Header.Get("Cache-Control"), Equals, "no-cache")
+	}
+	return resp.StatusCode, resp.Header.Get("ETag")
+}
+
+func (s *etagTestSuite) Test_ETag(c *C) {
+	status, etag := s.get(c, "/config/app.json", "")
+	c.Assert(status, Equals, http.StatusOK)
+
+	status, same := s.get(c, "/config/app.json", etag)
+	c.Assert(status, Equals, http.StatusNotModified)
+	c.Assert(same, Equals, etag)
+
+	status, _ = s.get(c, "/config/app.json", `"other", W/`+etag)
+	c.Assert(status, Equals, http.StatusNotModified)
+
+	status, _ = s.get(c, "/config/app.json", "*")
+	c.Assert(status, Equals, http.StatusNotModified)
+
+	status, _ = s.get(c, "/config/app.json", `"other"`)
+	c.Assert(status, Equals, http.StatusOK)
+
+	// The format is a part of the result.
+	status, yaml := s.get(c, "/config/app.json?format=yaml", etag)
+	c.Assert(status, Equals, http.StatusOK)
+	c.Assert(yaml, Not(Equals), etag)
+}
+
+func (s *etagTestSuite) Test_ETag_Dependencies(c *C) {
+	_, etag := s.get(c, "/config/app.json", "")
+
+	for _, file := range []string{"parent.json", "common.json", "app.json"} {
+		switch file {
+		case "parent.json":
+			s.write(c, file, `{"port": 9090}`)
+		case "common.json":
+			s.write(c, file, `{"level": "debug"}`)
+		case "app.json":
+			s.write(c, file, `{"@parent": {"$ref": "parent.json"}, "log": {"$ref": "common.json"}, "name": "app"}`)
+		}
+
+		status, next := s.get(c, "/config/app.json", etag)
+		c.Assert(status, Equals, http.StatusOK, Commentf(file))
+		c.Assert(next, Not(Equals), etag, Commentf(file))
+
+		status, _ = s.get(c, "/config/app.json", next)
+		c.Assert(status, Equals, http.StatusNotModified, Commentf(file))
+		etag = next
+	}
+
+	// The result isn't changed, so the ETag isn't changed either.
+	s.write(c, "common.json", `{ "level": "debug" }`)
+	status, next := s.get(c, "/config/app.json", etag)
+	c.Assert(status, Equals, http.StatusNotModified)
+	c.Assert(next, Equals, etag)
+
+	s.write(c, "parent.json", `{`)
+	status, _ = s.get(c, "/config/app.json", etag)
+	c.Assert(status, Equals, http.StatusUnprocessableEntity)
+}
@@ -2,25 +2,67 @@ package httpserver
 
 /*
 	It a HTTP server for simple management of browsing, starting and canceling of jobs.
+	It serves processed configs of the root dir too, see api.go.
 */
 
 import (
 	"context"
-	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"time"
+
+	"github.com/iostrovok/yacs-go/yacs-go/helper"
+	"github.com/iostrovok/yacs-go/yacs-go/loader"
+	"github.com/iostrovok/yacs-go/yacs-go/utils"
 )
 
 type contextKey string
 
 // We use settings if we want to pass parameters into http handler.
 type settings struct {
-	Dir      string
-	Ping     string
-	Username string
-	Password string
+	Dir string
+
+	// auth is nil if the server is open for everybody.
+	auth Authenticator
+	// processor resolves references of top level documents against Dir.
+	processor *helper.Processor
+	jobs      *jobManager
+	tags      *tagCache
+	watches   *watcher
+}
+
+// DefaultAddr is used if Config.Addr isn't set.
+const DefaultAddr = ":8080"
+
+// DefaultShutdownTimeout is used if Config.ShutdownTimeout isn't set.
+const DefaultShutdownTimeout = 30 * time.Second
+
+// Config of the server.
+type Config struct {
+	// Addr is the address to listen, DefaultAddr by default.
+	Addr string
+	// RootDir is the dir of served configs.
+	RootDir string
+	// Auth checks credentials of requests. Nil means no authentication.
+	Auth Authenticator
+	// CertFile and KeyFile turn on TLS.
+	CertFile string
+	KeyFile  string
+	// ShutdownTimeout is the time to finish active requests after cancel of the context of Run.
+	// Running jobs and watching requests are canceled.
+	ShutdownTimeout time.Duration
+	// AllowedHosts are hosts of remote "$ref", "@parent" and "@schemas" documents, e.g. "example.com"
+	// or "example.com:8080". Remote documents are not loaded if it's empty.
+	AllowedHosts []string
+	// JobHistory is the number of finished jobs which are kept, DefaultJobHistory by default.
+	JobHistory int
+	// MaxJobs is the number of jobs which may run at the same time, DefaultMaxJobs by default.
+	MaxJobs int
+	// WatchInterval is the period of checks of dependencies of watched configs, DefaultWatchInterval by default.
+	WatchInterval time.Duration
+	// Logger prints messages of the server, it may be nil.
+	Logger utils.Logger
 }
 
 func getTimeHelper(key string, r *http.Request) (time.Time, error) {
@@ -49,10 +91,8 @@ func jsonWrite(w http.ResponseWriter, what interface{}) {
 func wrapHandler(h http.HandlerFunc, s *settings) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
-		user, pass, ok := r.BasicAuth()
-
-		if !ok || subtle.ConstantTimeCompare([]byte(user), []byte(s.Username)) != 1 || subtle.ConstantTimeCompare([]byte(pass), []byte(s.Password)) != 1 {
-			w.Header().Set("WWW-Authenticate", `Basic realm="Duck out"`)
+		if s.auth != nil && !s.auth.Authenticate(r) {
+			w.Header().Set("WWW-Authenticate", s.auth.Challenge())
 			w.WriteHeader(401)
 			w.Write([]byte("Unauthorised.\n"))
 			return
@@ -85,47 +125,107 @@ func getContextHelper(r *http.Request) (*settings, error) {
 	return sets, nil
 }
 
-func handlerState(w http.ResponseWriter, r *http.Request) {
+func handleFileServer(dir, prefix string) http.HandlerFunc {
+	return http.StripPrefix(prefix, http.FileServer(http.Dir(dir))).ServeHTTP
+}
+
+func newMux(s *settings) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/static/", wrapHandler(handleFileServer(s.Dir, "/static/"), s))
+	mux.HandleFunc("/state/", wrapHandler(handlerState, s))
+	mux.HandleFunc("/jobs", wrapHandler(handlerJobs, s))
+	mux.HandleFunc("/jobs/", wrapHandler(handlerJobs, s))
+	mux.HandleFunc("/config/", wrapHandler(handlerConfig, s))
+	mux.HandleFunc("/watch/", wrapHandler(handlerWatch, s))
+	mux.HandleFunc("/process", wrapHandler(handlerProcess, s))
+	return mux
+}
 
-	sets, err := getContextHelper(r)
-	if err != nil {
-		jsonWrite(w, map[string]string{"error": fmt.Sprint(err)})
-		return
+// loaderOptions confine documents of clients to the root dir and allowed hosts,
+// so "$ref" can't read other files of the server or send requests to other hosts.
+func loaderOptions(cfg Config) []helper.Option {
+	remote := loader.NewHostsLoader(loader.NewHTTPLoader(loader.DefaultHTTPTimeout, nil), cfg.AllowedHosts...)
+	return []helper.Option{
+		helper.WithLoader("file", loader.DirLoader{Dir: cfg.RootDir}),
+		helper.WithLoader("http", remote),
+		helper.WithLoader("https", remote),
 	}
+}
 
-	fmt.Printf("sets: %s\n", sets)
-
-	res := map[string]string{
-		"error": "",
-		"id":    "jobID",
+func newSettings(cfg Config) *settings {
+	opts := loaderOptions(cfg)
+	return &settings{
+		Dir:       cfg.RootDir,
+		auth:      cfg.Auth,
+		processor: helper.NewProcessor(append([]helper.Option{helper.WithBaseDir(cfg.RootDir)}, opts...)...),
+		jobs:      newJobManager(cfg.RootDir, cfg.JobHistory, cfg.MaxJobs, opts),
+		tags:      newTagCache(),
+		watches:   newWatcher(cfg.WatchInterval),
 	}
-	jsonWrite(w, res)
 }
 
-func handleFileServer(dir, prefix string) http.HandlerFunc {
+// Handler returns handlers of all endpoints for configs of cfg.RootDir.
+func Handler(cfg Config) http.Handler {
+	return newMux(newSettings(cfg))
+}
 
-	fs := http.FileServer(http.Dir(dir))
-	realHandler := http.StripPrefix(prefix, fs).ServeHTTP
+// Run is main function. It starts the HTTP server and stops it gracefully when ctx is canceled.
+func Run(ctx context.Context, cfg Config) error {
 
-	return func(w http.ResponseWriter, req *http.Request) {
-		fmt.Println(req.URL)
-		realHandler(w, req)
+	if cfg.Addr == "" {
+		cfg.Addr = DefaultAddr
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		cfg.ShutdownTimeout = DefaultShutdownTimeout
+	}
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return fmt.Errorf("both TLS cert and key files must be set")
 	}
-}
 
-// Run is main function. It starts the HTTP server
-func Run(htmlDir string) {
+	printf := func(text string, args ...interface{}) {
+		if cfg.Logger != nil {
+			cfg.Logger.Printf(text, args...)
+		}
+	}
 
-	fmt.Println("SERVER.Run", "HTTP server starting...")
+	s := newSettings(cfg)
+	defer s.jobs.close()
+	defer s.watches.stop()
 
-	s := &settings{
-		Dir:      "string DIR",
-		Ping:     "string Ping",
-		Username: "Username",
-		Password: "Password",
+	srv := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           newMux(s),
+		ReadHeaderTimeout: 10 * time.Second,
 	}
+	// Watching requests never end by themselves, Shutdown would wait for them till the timeout.
+	srv.RegisterOnShutdown(s.watches.stop)
+
+	errs := make(chan error, 1)
+	go func() {
+		if cfg.CertFile != "" {
+			printf("HTTPS server is listening on %s, configs: %s", cfg.Addr, cfg.RootDir)
+			errs <- srv.ListenAndServeTLS(cfg.CertFile, cfg.KeyFile)
+			return
+		}
+		printf("HTTP server is listening on %s, configs: %s", cfg.Addr, cfg.RootDir)
+		errs <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	printf("HTTP server is shutting down...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
 
-	http.HandleFunc("/static/", wrapHandler(handleFileServer(htmlDir, "/static/"), s))
-	http.HandleFunc("/state/", wrapHandler(handlerState, s))
-	fmt.Println(http.ListenAndServe(":8080", nil))
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errs; err != http.ErrServerClosed {
+		return err
+	}
+	return nil
 }
@@ -0,0 +1,278 @@
+package httpserver_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/iostrovok/yacs-go/yacs-go/httpserver"
+
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func Test(t *testing.T) { TestingT(t) }
+
+type serverTestSuite struct {
+	server *httptest.Server
+}
+
+var _ = Suite(&serverTestSuite{})
+
+func (s *serverTestSuite) SetUpSuite(c *C) {
+	s.server = httptest.NewServer(httpserver.Handler(httpserver.Config{
+		RootDir: "testdata",
+		Auth:    httpserver.AnyAuth{httpserver.NewBasicAuth("user", "secret"), httpserver.NewTokenAuth("token-1")},
+	}))
+}
+
+func (s *serverTestSuite) TearDownSuite(c *C) {
+	s.server.Close()
+}
+
+func (s *serverTestSuite) do(c *C, method, path, body string) (*http.Response, string) {
+	req, err := http.NewRequest(method, s.server.URL+path, strings.NewReader(body))
+	c.Assert(err, IsNil)
+	req.SetBasicAuth("user", "secret")
+
+	resp, err := http.DefaultClient.Do(req)
+	c.Assert(err, IsNil)
+	defer resp.Body.Close()
+
+	out, err := ioutil.ReadAll(resp.Body)
+	c.Assert(err, IsNil)
+	return resp, string(out)
+}
+
+func apiError(c *C, body string) *httpserver.APIError {
+	var out struct {
+		Error *httpserver.APIError `json:"error"`
+	}
+	c.Assert(json.Unmarshal([]byte(body), &out), IsNil)
+	c.Assert(out.Error, NotNil)
+	return out.Error
+}
+
+// rootSuite is embedded by suites which change files, every test gets the server over its own temp dir.
+type rootSuite struct {
+	root   string
+	server *httptest.Server
+}
+
+// serve starts the server over the new temp dir, RootDir of cfg is replaced.
+func (s *rootSuite) serve(c *C, cfg httpserver.Config) {
+	s.root = c.MkDir()
+	cfg.RootDir = s.root
+	s.server = httptest.NewServer(httpserver.Handler(cfg))
+}
+
+func (s *rootSuite) TearDownTest(c *C) {
+	s.server.Close()
+}
+
+func (s *rootSuite) write(c *C, name, body string) {
+	file := filepath.Join(s.root, name)
+	c.Assert(os.MkdirAll(filepath.Dir(file), 0777), IsNil)
+	c.Assert(ioutil.WriteFile(file, []byte(body), 0666), IsNil)
+}
+
+// request returns the response as is, it's closed by the caller.
+func (s *rootSuite) request(c *C, method, path, body string, header map[string]string) *http.Response {
+	req, err := http.NewRequest(method, s.server.URL+path, strings.NewReader(body))
+	c.Assert(err, IsNil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	c.Assert(err, IsNil)
+	return resp
+}
+
+func (s *serverTestSuite) Test_Config(c *C) {
+	resp, body := s.do(c, "GET", "/config/app.json", "")
+	c.Assert(resp.StatusCode, Equals, http.StatusOK)
+	c.Assert(resp.Header.Get("Content-Type"), Equals, "application/json")
+	c.Assert(body, Equals, "{\n    \"name\": \"app\",\n    \"port\": 8080\n}\n")
+
+	resp, body = s.do(c, "GET", "/config/app.json?format=env", "")
+	c.Assert(resp.StatusCode, Equals, http.StatusOK)
+	c.Assert(body, Equals, "NAME=\"app\"\nPORT=8080\n")
+}
+
+func (s *serverTestSuite) Test_Config_Errors(c *C) {
+	resp, body := s.do(c, "GET", "/config/invalid.json", "")
+	c.Assert(resp.StatusCode, Equals, http.StatusUnprocessableEntity)
+	e := apiError(c, body)
+	c.Assert(e.Code, Equals, httpserver.CodeInvalidDocument)
+	c.Assert(e.Stage, Equals, "validate")
+	c.Assert(e.Failures, HasLen, 1)
+	c.Assert(e.Failures[0].Path, Equals, "/port")
+	c.Assert(e.Failures[0].URI, Equals, "invalid.json")
+	c.Assert(e.Failures[0].Line, Equals, 3)
+
+	resp, body = s.do(c, "GET", "/config/missing.json", "")
+	c.Assert(resp.StatusCode, Equals, http.StatusNotFound)
+	c.Assert(apiError(c, body).Code, Equals, httpserver.CodeNotFound)
+
+	resp, _ = s.do(c, "GET", "/config/%2e%2e/httpserver.go", "")
+	c.Assert(resp.StatusCode, Equals, http.StatusNotFound)
+
+	resp, body = s.do(c, "GET", "/config/app.json?format=xml", "")
+	c.Assert(resp.StatusCode, Equals, http.StatusBadRequest)
+	c.Assert(apiError(c, body).Code, Equals, httpserver.CodeBadRequest)
+
+	resp, body = s.do(c, "DELETE", "/config/app.json", "")
+	c.Assert(resp.StatusCode, Equals, http.StatusMethodNotAllowed)
+	c.Assert(resp.Header.Get("Allow"), Equals, "GET, HEAD")
+	c.Assert(apiError(c, body).Code, Equals, httpserver.CodeMethodNotAllowed)
+}
+
+func (s *serverTestSuite) Test_Process(c *C) {
+	resp, body := s.do(c, "POST", "/process?format=yaml", `{"@parent": {"$ref": "parent.json"}, "name": "posted"}`)
+	c.Assert(resp.StatusCode, Equals, http.StatusOK)
+	c.Assert(resp.Header.Get("Content-Type"), Equals, "application/yaml")
+	c.Assert(body, Equals, "name: posted\nport: 8080\n")
+
+	resp, body = s.do(c, "POST", "/process", "{\n\"a\": ")
+	c.Assert(resp.StatusCode, Equals, http.StatusBadRequest)
+	e := apiError(c, body)
+	c.Assert(e.Code, Equals, httpserver.CodeBadRequest)
+	c.Assert(e.Stage, Equals, "load")
+	c.Assert(e.Line, Equals, 2)
+
+	resp, body = s.do(c, "POST", "/process", `{"a": {"$ref": "missing.json"}}`)
+	c.Assert(resp.StatusCode, Equals, http.StatusUnprocessableEntity)
+	e = apiError(c, body)
+	c.Assert(e.Stage, Equals, "resolve")
+	c.Assert(e.Message, Not(Matches), ".*testdata.*")
+
+	resp, _ = s.do(c, "GET", "/process", "")
+	c.Assert(resp.StatusCode, Equals, http.StatusMethodNotAllowed)
+}
+
+func (s *serverTestSuite) Test_Process_Confined(c *C) {
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"remote": true}`))
+	}))
+	defer remote.Close()
+
+	for body, msg := range map[string]string{
+		`{"x": {"$ref": "/etc/passwd"}}`:                     ".*out of the root dir",
+		`{"x": {"$ref": "../httpserver_test.go"}}`:           ".*out of the root dir",
+		`{"x": {"$ref": "file:///etc/passwd"}}`:              ".*out of the root dir",
+		`{"x": {"$ref": "` + remote.URL + `/a.json"}}`:       ".*is not allowed",
+		`{"@parent": {"$ref": "` + remote.URL + `/a.json"}}`: ".*is not allowed",
+	} {
+		resp, out := s.do(c, "POST", "/process", body)
+		c.Assert(resp.StatusCode, Equals, http.StatusUnprocessableEntity, Commentf(body))
+		c.Assert(apiError(c, out).Message, Matches, msg, Commentf(body))
+	}
+
+	resp, out := s.do(c, "GET", "/config/escape.json", "")
+	c.Assert(resp.StatusCode, Equals, http.StatusUnprocessableEntity)
+	c.Assert(apiError(c, out).Message, Matches, ".*out of the root dir")
+
+	// Remote documents of allowed hosts are loaded.
+	server := httptest.NewServer(httpserver.Handler(httpserver.Config{RootDir: "testdata", AllowedHosts: []string{"127.0.0.1"}}))
+	defer server.Close()
+
+	res, err := http.Post(server.URL+"/process?format=json-compact", "application/json", strings.NewReader(`{"x": {"$ref": "`+remote.URL+`/a.json"}}`))
+	c.Assert(err, IsNil)
+	defer res.Body.Close()
+	c.Assert(res.StatusCode, Equals, http.StatusOK)
+}
+
+func (s *serverTestSuite) status(c *C, auth func(r *http.Request)) int {
+	req, err := http.NewRequest("GET", s.server.URL+"/config/app.json", nil)
+	c.Assert(err, IsNil)
+	auth(req)
+
+	resp, err := http.DefaultClient.Do(req)
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func (s *serverTestSuite) Test_Auth(c *C) {
+	c.Assert(s.status(c, func(r *http.Request) {}), Equals, http.StatusUnauthorized)
+	c.Assert(s.status(c, func(r *http.Request) { r.SetBasicAuth("user", "wrong") }), Equals, http.StatusUnauthorized)
+	c.Assert(s.status(c, func(r *http.Request) { r.Header.Set("Authorization", "Bearer token-2") }), Equals, http.StatusUnauthorized)
+	c.Assert(s.status(c, func(r *http.Request) { r.Header.Set("Authorization", "Bearer token-1") }), Equals, http.StatusOK)
+}
+
+func (s *serverTestSuite) Test_BasicAuth_EmptyPassword(c *C) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.SetBasicAuth("user", "")
+	c.Assert(httpserver.NewBasicAuth("user", "").Authenticate(r), Equals, false)
+}
+
+func (s *serverTestSuite) Test_Htpasswd(c *C) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("bcrypt-pass"), bcrypt.MinCost)
+	c.Assert(err, IsNil)
+
+	file := filepath.Join(c.MkDir(), "htpasswd")
+	body := "# users\nalice:" + string(hash) + "\nbob:{SHA}qUqP5cyxm6YcTAhz05Hph5gvu9M=\n"
+	c.Assert(ioutil.WriteFile(file, []byte(body), 0600), IsNil)
+
+	auth, err := httpserver.LoadHtpasswd(file)
+	c.Assert(err, IsNil)
+
+	check := func(user, pass string) bool {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.SetBasicAuth(user, pass)
+		return auth.Authenticate(r)
+	}
+	c.Assert(check("alice", "bcrypt-pass"), Equals, true)
+	c.Assert(check("alice", "test"), Equals, false)
+	c.Assert(check("bob", "test"), Equals, true)
+	c.Assert(check("carol", "test"), Equals, false)
+
+	c.Assert(ioutil.WriteFile(file, []byte("dave:$apr1$x$y\n"), 0600), IsNil)
+	_, err = httpserver.LoadHtpasswd(file)
+	c.Assert(err, ErrorMatches, ".*MD5 \\(apr1\\) hashes are not supported.*")
+
+	for _, hash := range []string{"plain", "rqXKjp6mKVxZw", "$5$salt$hash", "$6$salt$hash", "{PLAIN}plain"} {
+		c.Assert(ioutil.WriteFile(file, []byte("erin:"+hash+"\n"), 0600), IsNil)
+		_, err = httpserver.LoadHtpasswd(file)
+		c.Assert(err, ErrorMatches, ".*:1: unknown hash scheme.*", Commentf(hash))
+	}
+}
+
+func (s *serverTestSuite) Test_Tokens(c *C) {
+	file := filepath.Join(c.MkDir(), "tokens")
+	c.Assert(ioutil.WriteFile(file, []byte("# ci\n t1 \n\nt2\n"), 0600), IsNil)
+
+	auth, err := httpserver.LoadTokens(file)
+	c.Assert(err, IsNil)
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "bearer t2")
+	c.Assert(auth.Authenticate(r), Equals, true)
+	r.Header.Set("Authorization", "Bearer # ci")
+	c.Assert(auth.Authenticate(r), Equals, false)
+}
+
+func (s *serverTestSuite) Test_Run_Shutdown(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- httpserver.Run(ctx, httpserver.Config{Addr: "127.0.0.1:0", RootDir: "testdata", ShutdownTimeout: time.Second})
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	c.Assert(<-done, IsNil)
+
+	err := httpserver.Run(context.Background(), httpserver.Config{CertFile: "cert.pem"})
+	c.Assert(err, ErrorMatches, "both TLS cert and key files must be set")
+}
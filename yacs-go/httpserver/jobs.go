@@ -0,0 +1,519 @@
+package httpserver
+
+/*
+
+Jobs run "batchdir" processing of dirs of the root dir in the background.
+
+	POST   /jobs         starts the job by JobRequest, the response is JobState with 202 status
+	GET    /jobs         returns states of all known jobs
+	GET    /state/<id>   returns the state of the job
+	DELETE /jobs/<id>    cancels the job
+
+Finished jobs are kept in the bounded history, the oldest ones are forgotten first.
+
+Outdir must be new or empty dir or the outdir of previous jobs, so jobs can't overwrite
+configs which are served by the server. Outdirs of jobs are marked by the ".yacs-job" file.
+
+*/
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iostrovok/yacs-go/yacs-go/batch"
+	"github.com/iostrovok/yacs-go/yacs-go/format"
+	"github.com/iostrovok/yacs-go/yacs-go/helper"
+	"github.com/iostrovok/yacs-go/yacs-go/utils"
+)
+
+const (
+	// DefaultJobHistory is used if Config.JobHistory isn't set.
+	DefaultJobHistory = 100
+	// DefaultMaxJobs is used if Config.MaxJobs isn't set.
+	DefaultMaxJobs = 4
+)
+
+// jobMarker is the file which marks outdirs of jobs.
+const jobMarker = ".yacs-job"
+
+// errTooManyJobs is returned by start if Config.MaxJobs jobs are running.
+var errTooManyJobs = errors.New("too many running jobs, try later")
+
+// States of jobs.
+const (
+	JobRunning  = "running"
+	JobDone     = "done"
+	JobFailed   = "failed"
+	JobCanceled = "canceled"
+)
+
+// JobRequest is the body of POST /jobs. Dirs are relative to the root dir of the server.
+// Workers are bounded by the number of CPUs.
+type JobRequest struct {
+	InDir           string `json:"indir"`
+	OutDir          string `json:"outdir"`
+	Format          string `json:"format,omitempty"`
+	SkipResolution  bool   `json:"skip_resolution,omitempty"`
+	SkipInheritance bool   `json:"skip_inheritance,omitempty"`
+	SkipValidation  bool   `json:"skip_validation,omitempty"`
+	Locks           string `json:"locks,omitempty"`
+	Workers         int    `json:"workers,omitempty"`
+	FailFast        bool   `json:"fail_fast,omitempty"`
+	Incremental     bool   `json:"incremental,omitempty"`
+}
+
+// JobFailure is the error of single file of the job.
+type JobFailure struct {
+	File  string `json:"file"`
+	Stage string `json:"stage"`
+	Error string `json:"error"`
+}
+
+// JobState is the progress of the job.
+type JobState struct {
+	ID      string     `json:"id"`
+	State   string     `json:"state"`
+	Request JobRequest `json:"request"`
+	Total   int        `json:"total"`
+	// Done is the number of processed files with or without errors.
+	Done    int `json:"done"`
+	Failed  int `json:"failed"`
+	Skipped int `json:"skipped"`
+	// Current are files which are being processed now.
+	Current  []string     `json:"current"`
+	Failures []JobFailure `json:"failures"`
+	// Error is the problem of the whole job, e.g. missing indir.
+	Error    string     `json:"error,omitempty"`
+	Started  time.Time  `json:"started"`
+	Finished *time.Time `json:"finished,omitempty"`
+}
+
+type job struct {
+	mu      sync.Mutex
+	state   JobState
+	current map[string]bool
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// snapshot returns the copy of the state.
+func (j *job) snapshot() JobState {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	out := j.state
+	out.Current = make([]string, 0, len(j.current))
+	for file := range j.current {
+		out.Current = append(out.Current, file)
+	}
+	sort.Strings(out.Current)
+	out.Failures = append([]JobFailure{}, j.state.Failures...)
+	return out
+}
+
+func (j *job) finished() bool {
+	select {
+	case <-j.done:
+		return true
+	default:
+		return false
+	}
+}
+
+type jobManager struct {
+	mu      sync.Mutex
+	root    string
+	history int
+	// max is the number of jobs which may run at the same time.
+	max int
+	// loaders are options of processors of all jobs, see loaderOptions.
+	loaders []helper.Option
+	jobs    map[string]*job
+	// order is the list of IDs from the oldest job.
+	order []string
+	wg    sync.WaitGroup
+	ctx   context.Context
+	stop  context.CancelFunc
+}
+
+func newJobManager(root string, history, max int, loaders []helper.Option) *jobManager {
+	if history <= 0 {
+		history = DefaultJobHistory
+	}
+	if max <= 0 {
+		max = DefaultMaxJobs
+	}
+	// Dirs of jobs are compared by prefixes, so they must be absolute.
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	return &jobManager{root: root, history: history, max: max, loaders: loaders, jobs: map[string]*job{}, ctx: ctx, stop: stop}
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// dir returns the path of the dir of the request, it never leaves the root dir.
+func (m *jobManager) dir(name, value string) (string, error) {
+	if strings.TrimSpace(value) == "" {
+		return "", fmt.Errorf("'%s' is required", name)
+	}
+	return filepath.Join(m.root, filepath.FromSlash(configFile("", value))), nil
+}
+
+// options converts the request to processor and batch options like the command line does.
+func (m *jobManager) options(req JobRequest) (*helper.Processor, batch.Options, error) {
+
+	opts := batch.Options{Workers: req.Workers, FailFast: req.FailFast, Format: format.JSON}
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	if opts.Workers > runtime.NumCPU() {
+		opts.Workers = runtime.NumCPU()
+	}
+
+	if req.Format != "" {
+		f, err := format.Parse(req.Format)
+		if err != nil {
+			return nil, opts, err
+		}
+		opts.Format = f
+	}
+
+	lockMode := helper.LockIgnore
+	if req.Locks != "" {
+		mode, err := helper.ParseLockMode(req.Locks)
+		if err != nil {
+			return nil, opts, err
+		}
+		lockMode = mode
+	}
+
+	stages := helper.AllStages
+	if req.SkipResolution {
+		stages &^= helper.StageResolve
+	}
+	if req.SkipInheritance {
+		stages &^= helper.StageInherit
+	}
+	if req.SkipValidation {
+		stages &^= helper.StageValidate
+	}
+
+	if req.Incremental {
+		opts.Settings = fmt.Sprintf("format=%s stages=%d locks=%s", opts.Format, stages, lockMode)
+	}
+
+	return helper.NewProcessor(append([]helper.Option{helper.WithStages(stages), helper.WithLockMode(lockMode)}, m.loaders...)...), opts, nil
+}
+
+// start checks the request and runs the job in the background.
+func (m *jobManager) start(req JobRequest) (*job, error) {
+
+	inDir, err := m.dir("indir", req.InDir)
+	if err != nil {
+		return nil, err
+	}
+	outDir, err := m.dir("outdir", req.OutDir)
+	if err != nil {
+		return nil, err
+	}
+	// Results would overwrite sources. Outdir inside of indir is skipped by run.
+	if utils.IsWithinDir(inDir, outDir) {
+		return nil, fmt.Errorf("'outdir' must not be 'indir' or contain it")
+	}
+
+	p, opts, err := m.options(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := markOutDir(outDir); err != nil {
+		return nil, err
+	}
+	if req.Incremental {
+		opts.Manifest = filepath.Join(outDir, batch.ManifestName)
+	}
+
+	ctx, cancel := context.WithCancel(m.ctx)
+	j := &job{
+		state:   JobState{ID: newJobID(), State: JobRunning, Request: req, Started: time.Now()},
+		current: map[string]bool{},
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+
+	opts.Start = func(bf utils.FileForProcess) {
+		j.mu.Lock()
+		j.current[relative(m.root, bf.From)] = true
+		j.mu.Unlock()
+	}
+	opts.Done = func(bf utils.FileForProcess, err error) {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		delete(j.current, relative(m.root, bf.From))
+		j.state.Done++
+		if f, ok := err.(*batch.Failure); ok {
+			j.state.Failed++
+			j.state.Failures = append(j.state.Failures, JobFailure{
+				File:  relative(m.root, f.File),
+				Stage: f.Stage,
+				Error: strings.Replace(f.Err.Error(), filepath.Clean(m.root)+string(filepath.Separator), "", -1),
+			})
+		}
+	}
+
+	if err := m.add(j); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer close(j.done)
+		defer cancel()
+		m.run(ctx, j, p, inDir, outDir, opts)
+	}()
+
+	return j, nil
+}
+
+func (m *jobManager) run(ctx context.Context, j *job, p *helper.Processor, inDir, outDir string, opts batch.Options) {
+
+	finish := func(state, msg string) {
+		now := time.Now()
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		j.state.State, j.state.Error, j.state.Finished = state, msg, &now
+		j.current = map[string]bool{}
+	}
+
+	all, err := utils.FindAllFiles(inDir, outDir, "")
+	if err != nil {
+		finish(JobFailed, "indir '"+j.state.Request.InDir+"' can't be read")
+		return
+	}
+
+	// Results of previous jobs are not sources.
+	files := all[:0]
+	for _, bf := range all {
+		if !utils.IsWithinDir(bf.From, outDir) {
+			files = append(files, bf)
+		}
+	}
+
+	j.mu.Lock()
+	j.state.Total = len(files)
+	j.mu.Unlock()
+
+	report := batch.Run(ctx, p, files, opts)
+
+	j.mu.Lock()
+	j.state.Skipped = report.Skipped
+	j.mu.Unlock()
+
+	// FailFast stops the batch by its own context, ctx is canceled by DELETE or by shutdown only.
+	switch {
+	case ctx.Err() != nil:
+		finish(JobCanceled, "")
+	case report.Err() != nil:
+		finish(JobFailed, report.Err().Error())
+	default:
+		finish(JobDone, "")
+	}
+}
+
+// add saves the job and forgets the oldest finished jobs over the history limit.
+// It fails if the max number of jobs are running.
+func (m *jobManager) add(j *job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	finished := 0
+	for _, id := range m.order {
+		if m.jobs[id].finished() {
+			finished++
+		}
+	}
+	if len(m.order)-finished >= m.max {
+		return errTooManyJobs
+	}
+
+	m.jobs[j.state.ID] = j
+	m.order = append(m.order, j.state.ID)
+
+	order := m.order[:0]
+	for _, id := range m.order {
+		if finished > m.history && m.jobs[id].finished() {
+			delete(m.jobs, id)
+			finished--
+			continue
+		}
+		order = append(order, id)
+	}
+	m.order = order
+	return nil
+}
+
+func (m *jobManager) get(id string) (*job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, find := m.jobs[id]
+	return j, find
+}
+
+func (m *jobManager) list() []JobState {
+	m.mu.Lock()
+	jobs := make([]*job, 0, len(m.order))
+	for _, id := range m.order {
+		jobs = append(jobs, m.jobs[id])
+	}
+	m.mu.Unlock()
+
+	out := make([]JobState, 0, len(jobs))
+	for _, j := range jobs {
+		out = append(out, j.snapshot())
+	}
+	return out
+}
+
+// close cancels all jobs and waits for them.
+func (m *jobManager) close() {
+	m.stop()
+	m.wg.Wait()
+}
+
+// within checks that the cleaned path is dir or inside of it.
+// markOutDir checks that the dir is new or empty or it's the outdir of previous jobs and marks it.
+func markOutDir(dir string) error {
+
+	info, err := os.Stat(dir)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return fmt.Errorf("'outdir' can't be read")
+	case !info.IsDir():
+		return fmt.Errorf("'outdir' must be a dir")
+	default:
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return fmt.Errorf("'outdir' can't be read")
+		}
+		if _, err := os.Stat(filepath.Join(dir, jobMarker)); len(entries) > 0 && err != nil {
+			return fmt.Errorf("'outdir' must be new or empty dir or the outdir of previous jobs")
+		}
+	}
+
+	return utils.SaveFile(filepath.Join(dir, jobMarker), nil, 0666)
+}
+
+func jobNotFound(id string) *APIError {
+	return &APIError{Status: http.StatusNotFound, Code: CodeNotFound, Message: "job '" + id + "' is not found"}
+}
+
+// handlerJobs serves POST /jobs, GET /jobs and DELETE /jobs/<id>.
+func handlerJobs(w http.ResponseWriter, r *http.Request) {
+
+	sets, err := getContextHelper(r)
+	if err != nil {
+		writeError(w, newError(http.StatusInternalServerError, CodeInternal, err))
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/jobs"), "/")
+	if id != "" {
+		if !allowMethods(w, r, http.MethodDelete) {
+			return
+		}
+
+		j, find := sets.jobs.get(id)
+		if !find {
+			writeError(w, jobNotFound(id))
+			return
+		}
+
+		if j.finished() {
+			writeError(w, &APIError{Status: http.StatusConflict, Code: CodeConflict, Message: "job '" + id + "' is finished already"})
+			return
+		}
+
+		j.cancel()
+		<-j.done
+		writeJSON(w, http.StatusOK, j.snapshot())
+		return
+	}
+
+	if !allowMethods(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, sets.jobs.list())
+		return
+	}
+
+	var req JobRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxBodySize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeError(w, newError(http.StatusBadRequest, CodeBadRequest, err))
+		return
+	}
+
+	j, err := sets.jobs.start(req)
+	if err == errTooManyJobs {
+		writeError(w, newError(http.StatusTooManyRequests, CodeTooManyRequests, err))
+		return
+	}
+	if err != nil {
+		writeError(w, newError(http.StatusBadRequest, CodeBadRequest, err))
+		return
+	}
+
+	state := j.snapshot()
+	w.Header().Set("Location", "/state/"+state.ID)
+	writeJSON(w, http.StatusAccepted, state)
+}
+
+// handlerState serves GET /state/<id>.
+func handlerState(w http.ResponseWriter, r *http.Request) {
+
+	sets, err := getContextHelper(r)
+	if err != nil {
+		writeError(w, newError(http.StatusInternalServerError, CodeInternal, err))
+		return
+	}
+
+	if !allowMethods(w, r, http.MethodGet, http.MethodHead) {
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/state"), "/")
+	j, find := sets.jobs.get(id)
+	if !find {
+		writeError(w, jobNotFound(id))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, j.snapshot())
+}
@@ -0,0 +1,170 @@
+package httpserver_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/iostrovok/yacs-go/yacs-go/httpserver"
+
+	. "gopkg.in/check.v1"
+)
+
+type jobsTestSuite struct {
+	rootSuite
+}
+
+var _ = Suite(&jobsTestSuite{})
+
+func (s *jobsTestSuite) SetUpTest(c *C) {
+	s.serve(c, httpserver.Config{JobHistory: 2, MaxJobs: 1, AllowedHosts: []string{"127.0.0.1"}})
+
+	s.write(c, "in/a.json", `{"a": 1}`)
+	s.write(c, "in/sub/b.yaml", "b: 2\n")
+	s.write(c, "in/bad.json", `{"b": {"$ref": "missing.json"}}`)
+}
+
+func (s *jobsTestSuite) do(c *C, method, path, body string, out interface{}) int {
+	resp := s.request(c, method, path, body, nil)
+	defer resp.Body.Close()
+
+	if out != nil {
+		c.Assert(json.NewDecoder(resp.Body).Decode(out), IsNil)
+	}
+	return resp.StatusCode
+}
+
+func (s *jobsTestSuite) start(c *C, body string) httpserver.JobState {
+	var state httpserver.JobState
+	c.Assert(s.do(c, "POST", "/jobs", body, &state), Equals, http.StatusAccepted)
+	c.Assert(state.ID, Not(Equals), "")
+	return state
+}
+
+func (s *jobsTestSuite) wait(c *C, id string) httpserver.JobState {
+	var state httpserver.JobState
+	for i := 0; i < 500; i++ {
+		c.Assert(s.do(c, "GET", "/state/"+id, "", &state), Equals, http.StatusOK)
+		if state.State != httpserver.JobRunning {
+			return state
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Fatal("the job isn't finished")
+	return state
+}
+
+func (s *jobsTestSuite) Test_Job(c *C) {
+	state := s.wait(c, s.start(c, `{"indir": "in", "outdir": "out", "format": "yaml"}`).ID)
+
+	c.Assert(state.State, Equals, httpserver.JobFailed)
+	c.Assert(state.Total, Equals, 3)
+	c.Assert(state.Done, Equals, 3)
+	c.Assert(state.Failed, Equals, 1)
+	c.Assert(state.Current, HasLen, 0)
+	c.Assert(state.Failures, HasLen, 1)
+	c.Assert(state.Failures[0].File, Equals, "in/bad.json")
+	c.Assert(state.Failures[0].Stage, Equals, "resolve")
+	c.Assert(state.Finished, NotNil)
+
+	body, err := ioutil.ReadFile(filepath.Join(s.root, "out/sub/b.yaml"))
+	c.Assert(err, IsNil)
+	c.Assert(string(body), Equals, "b: 2\n")
+
+	c.Assert(os.Remove(filepath.Join(s.root, "in/bad.json")), IsNil)
+	state = s.wait(c, s.start(c, `{"indir": "in", "outdir": "/../../out", "incremental": true}`).ID)
+	c.Assert(state.State, Equals, httpserver.JobDone)
+	c.Assert(state.Total, Equals, 2)
+
+	state = s.wait(c, s.start(c, `{"indir": "in", "outdir": "out", "incremental": true}`).ID)
+	c.Assert(state.State, Equals, httpserver.JobDone)
+	c.Assert(state.Skipped, Equals, 2)
+	c.Assert(state.Done, Equals, 0)
+
+	state = s.wait(c, s.start(c, `{"indir": "missing", "outdir": "out"}`).ID)
+	c.Assert(state.State, Equals, httpserver.JobFailed)
+	c.Assert(state.Error, Equals, "indir 'missing' can't be read")
+}
+
+func (s *jobsTestSuite) Test_Job_BadRequest(c *C) {
+	s.write(c, "conf/app.json", `{"app": 1}`)
+
+	for _, body := range []string{`{"outdir": "out"}`, `{"indir": "in", "outdir": "in"}`, `{"indir": "in", "outdir": "."}`, `{"indir": "in/sub", "outdir": "/../in"}`, `{"indir": "in", "outdir": "out", "format": "xml"}`, `{"indir": "in", "outdir": "out", "stages": 1}`, `[`,
+		// Outdir must be new or empty dir or the outdir of previous jobs.
+		`{"indir": "in", "outdir": "conf"}`, `{"indir": "in/sub", "outdir": "in/a.json"}`} {
+		var e struct{ Error *httpserver.APIError }
+		c.Assert(s.do(c, "POST", "/jobs", body, &e), Equals, http.StatusBadRequest, Commentf(body))
+		c.Assert(e.Error.Code, Equals, httpserver.CodeBadRequest)
+	}
+
+	c.Assert(s.do(c, "GET", "/state/unknown", "", nil), Equals, http.StatusNotFound)
+	c.Assert(s.do(c, "DELETE", "/jobs/unknown", "", nil), Equals, http.StatusNotFound)
+	c.Assert(s.do(c, "PUT", "/jobs", "", nil), Equals, http.StatusMethodNotAllowed)
+}
+
+func (s *jobsTestSuite) Test_Job_Cancel(c *C) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write([]byte(`{"slow": true}`))
+	}))
+	defer slow.Close()
+
+	s.write(c, "in/0.json", `{"s": {"$ref": "`+slow.URL+`/s.json"}}`)
+	id := s.start(c, `{"indir": "in", "outdir": "out", "workers": 1}`).ID
+
+	var state httpserver.JobState
+	for i := 0; len(state.Current) == 0 && i < 500; i++ {
+		c.Assert(s.do(c, "GET", "/state/"+id, "", &state), Equals, http.StatusOK)
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Assert(state.Current, DeepEquals, []string{"in/0.json"})
+
+	var e struct{ Error *httpserver.APIError }
+	c.Assert(s.do(c, "POST", "/jobs", `{"indir": "in", "outdir": "out2", "workers": 1000000}`, &e), Equals, http.StatusTooManyRequests)
+	c.Assert(e.Error.Code, Equals, httpserver.CodeTooManyRequests)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+	}()
+
+	c.Assert(s.do(c, "DELETE", "/jobs/"+id, "", &state), Equals, http.StatusOK)
+	c.Assert(state.State, Equals, httpserver.JobCanceled)
+	c.Assert(state.Done, Equals, 1)
+	c.Assert(state.Total, Equals, 4)
+
+	c.Assert(s.do(c, "DELETE", "/jobs/"+id, "", &e), Equals, http.StatusConflict)
+	c.Assert(e.Error.Code, Equals, httpserver.CodeConflict)
+}
+
+func (s *jobsTestSuite) Test_Job_History(c *C) {
+	ids := []string{}
+	for i := 0; i < 4; i++ {
+		id := s.start(c, `{"indir": "in/sub", "outdir": "out"}`).ID
+		s.wait(c, id)
+		ids = append(ids, id)
+	}
+
+	var list []httpserver.JobState
+	c.Assert(s.do(c, "GET", "/jobs", "", &list), Equals, http.StatusOK)
+	c.Assert(list, HasLen, 3)
+	c.Assert(list[0].ID, Equals, ids[1])
+	c.Assert(list[2].ID, Equals, ids[3])
+
+	c.Assert(s.do(c, "GET", "/state/"+ids[0], "", nil), Equals, http.StatusNotFound)
+}
+
+func (s *jobsTestSuite) Test_Job_OutDirInside(c *C) {
+	for i := 0; i < 2; i++ {
+		state := s.wait(c, s.start(c, `{"indir": "in", "outdir": "in/out"}`).ID)
+		c.Assert(state.Total, Equals, 3)
+	}
+
+	_, err := os.Stat(filepath.Join(s.root, "in/out/sub/b.json"))
+	c.Assert(err, IsNil)
+}
@@ -0,0 +1,251 @@
+package httpserver
+
+/*
+
+Watching of configs.
+
+	GET /watch/<path>[?format=yaml][&patch=true][&timeout=30s]
+
+With "Accept: text/event-stream" it's the stream of Server-Sent Events:
+
+	id: "6f1ed002ab5595859014ebf0951522d9"
+	event: change
+	data: {"etag":"\"6f1ed002ab5595859014ebf0951522d9\"","patch":[{"op":"replace","path":"/port","value":9090}]}
+
+The first event is sent at once if the ETag of If-None-Match or Last-Event-ID doesn't match the config,
+the next ones are sent when any dependency of the config is changed and the result differs
+or the config is fixed after the failure.
+"patch" is JSON Patch from the previous version, it's set with "patch=true" only.
+Errors of processing are "failure" events with APIError in data, watching goes on.
+
+Other requests are long polling: the response is ConfigChange as soon as the ETag of If-None-Match doesn't match
+the config, 304 is returned if the config isn't changed during "timeout".
+
+Dependencies are checked every Config.WatchInterval.
+
+*/
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iostrovok/yacs-go/yacs-go/diff"
+	"github.com/iostrovok/yacs-go/yacs-go/format"
+)
+
+const (
+	// DefaultWatchInterval is used if Config.WatchInterval isn't set.
+	DefaultWatchInterval = time.Second
+	// DefaultWatchTimeout is the time of long polling if "timeout" isn't set.
+	DefaultWatchTimeout = 30 * time.Second
+	// MaxWatchTimeout bounds "timeout" of long polling.
+	MaxWatchTimeout = 5 * time.Minute
+)
+
+// ConfigChange is the event of GET /watch/<path>.
+type ConfigChange struct {
+	ETag string `json:"etag"`
+	// Patch turns the previous version into the new one, it's set on request only.
+	Patch []diff.PatchOperation `json:"patch,omitempty"`
+}
+
+// watcher stops all watching requests on shutdown of the server, they never end by themselves.
+type watcher struct {
+	interval time.Duration
+	ctx      context.Context
+	stop     context.CancelFunc
+}
+
+func newWatcher(interval time.Duration) *watcher {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	return &watcher{interval: interval, ctx: ctx, stop: stop}
+}
+
+// version is the known state of the watched config.
+type version struct {
+	etag string
+	doc  interface{}
+	// failure is the message of the last error of processing, it's sent once.
+	failure string
+}
+
+// next waits for the new version of the config. It returns the change, the error of processing or false
+// if ctx is done.
+func (wt *watcher) next(ctx context.Context, sets *settings, f format.Format, rel string, prev *version, withPatch bool) (*ConfigChange, *APIError, bool) {
+
+	ticker := time.NewTicker(wt.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, nil, false
+		case <-wt.ctx.Done():
+			return nil, nil, false
+		case <-ticker.C:
+		}
+
+		// After the failure the config is processed anyway, other requests may have stored its ETag again.
+		if etag, ok := sets.tags.lookup(tagKey(f, rel)); ok && etag == prev.etag && prev.failure == "" {
+			continue
+		}
+
+		res, _, etag, apiErr := loadConfig(sets, f, rel)
+		if apiErr != nil {
+			if apiErr.Message == prev.failure {
+				continue
+			}
+			prev.failure = apiErr.Message
+			return nil, apiErr, true
+		}
+
+		// The client has got the failure, so the fixed config is sent even if it's the same as before.
+		recovered := prev.failure != ""
+		prev.failure = ""
+
+		if etag == prev.etag && !recovered {
+			continue
+		}
+
+		change := &ConfigChange{ETag: etag}
+		if withPatch && prev.doc != nil {
+			change.Patch = diff.Patch(diff.Changes(prev.doc, res.Doc))
+		}
+		prev.etag, prev.doc = etag, res.Doc
+		return change, nil, true
+	}
+}
+
+// watchOptions reads "patch" and "timeout" of the query.
+func watchOptions(r *http.Request) (bool, time.Duration, *APIError) {
+
+	query := r.URL.Query()
+
+	withPatch := false
+	if v := query.Get("patch"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return false, 0, &APIError{Status: http.StatusBadRequest, Code: CodeBadRequest, Message: "patch must be true or false"}
+		}
+		withPatch = b
+	}
+
+	timeout := DefaultWatchTimeout
+	if v := query.Get("timeout"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return false, 0, &APIError{Status: http.StatusBadRequest, Code: CodeBadRequest, Message: "timeout must be positive duration, e.g. 30s"}
+		}
+		timeout = d
+	}
+	if timeout > MaxWatchTimeout {
+		timeout = MaxWatchTimeout
+	}
+
+	return withPatch, timeout, nil
+}
+
+func handlerWatch(w http.ResponseWriter, r *http.Request) {
+
+	sets, err := getContextHelper(r)
+	if err != nil {
+		writeError(w, newError(http.StatusInternalServerError, CodeInternal, err))
+		return
+	}
+
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	withPatch, timeout, apiErr := watchOptions(r)
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+
+	f, rel, ok := findConfig(w, r, sets, "/watch/")
+	if !ok {
+		return
+	}
+
+	res, _, etag, apiErr := loadConfig(sets, f, rel)
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	current := &version{etag: etag, doc: res.Doc}
+
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		streamChanges(w, r, sets, f, rel, current, withPatch)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+
+	if !notModified(r, etag) {
+		writeJSON(w, http.StatusOK, &ConfigChange{ETag: etag})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	change, apiErr, ok := sets.watches.next(ctx, sets, f, rel, current, withPatch)
+	switch {
+	case !ok:
+		writeNotModified(w, etag)
+	case apiErr != nil:
+		writeError(w, apiErr)
+	default:
+		writeJSON(w, http.StatusOK, change)
+	}
+}
+
+// streamChanges sends events until the client goes away or the server is stopped.
+func streamChanges(w http.ResponseWriter, r *http.Request, sets *settings, f format.Format, rel string, current *version, withPatch bool) {
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, &APIError{Status: http.StatusInternalServerError, Code: CodeInternal, Message: "streaming is not supported"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	send := func(event, id string, data interface{}) {
+		body, _ := json.Marshal(data)
+		if id != "" {
+			fmt.Fprintf(w, "id: %s\n", id)
+		}
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, body)
+		flusher.Flush()
+	}
+
+	// Browsers send the last ETag as Last-Event-ID when they reconnect.
+	if known := r.Header.Get("Last-Event-ID"); known != current.etag && !notModified(r, current.etag) {
+		send("change", current.etag, &ConfigChange{ETag: current.etag})
+	}
+
+	for {
+		change, apiErr, ok := sets.watches.next(r.Context(), sets, f, rel, current, withPatch)
+		switch {
+		case !ok:
+			return
+		case apiErr != nil:
+			send("failure", "", apiErr)
+		default:
+			send("change", change.ETag, change)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package httpserver_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iostrovok/yacs-go/yacs-go/httpserver"
+
+	. "gopkg.in/check.v1"
+)
+
+type watchTestSuite struct {
+	rootSuite
+}
+
+var _ = Suite(&watchTestSuite{})
+
+// change is ConfigChange with the generic patch.
+type change struct {
+	ETag  string                   `json:"etag"`
+	Patch []map[string]interface{} `json:"patch"`
+}
+
+func (s *watchTestSuite) SetUpTest(c *C) {
+	s.serve(c, httpserver.Config{WatchInterval: 10 * time.Millisecond})
+
+	s.write(c, "parent.json", `{"port": 8080}`)
+	s.write(c, "app.json", `{"@parent": {"$ref": "parent.json"}, "name": "app"}`)
+}
+
+// get is GET request with headers.
+func (s *watchTestSuite) get(c *C, path string, header map[string]string) *http.Response {
+	return s.request(c, "GET", path, "", header)
+}
+
+func (s *watchTestSuite) poll(c *C, path, etag string) (int, *change) {
+	resp := s.get(c, path, map[string]string{"If-None-Match": etag})
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, nil
+	}
+
+	out := &change{}
+	c.Assert(json.NewDecoder(resp.Body).Decode(out), IsNil)
+	return resp.StatusCode, out
+}
+
+func (s *watchTestSuite) Test_Watch_LongPoll(c *C) {
+	resp := s.get(c, "/config/app.json", nil)
+	resp.Body.Close()
+	etag := resp.Header.Get("ETag")
+
+	status, ch := s.poll(c, "/watch/app.json", "")
+	c.Assert(status, Equals, http.StatusOK)
+	c.Assert(ch.ETag, Equals, etag)
+
+	status, _ = s.poll(c, "/watch/app.json?timeout=50ms", etag)
+	c.Assert(status, Equals, http.StatusNotModified)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		s.write(c, "parent.json", `{"port": 9090}`)
+	}()
+
+	status, ch = s.poll(c, "/watch/app.json?patch=true&timeout=5s", etag)
+	c.Assert(status, Equals, http.StatusOK)
+	c.Assert(ch.ETag, Not(Equals), etag)
+	c.Assert(ch.Patch, DeepEquals, []map[string]interface{}{{"op": "replace", "path": "/port", "value": 9090.0}})
+
+	resp = s.get(c, "/config/app.json", map[string]string{"If-None-Match": ch.ETag})
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, Equals, http.StatusNotModified)
+}
+
+func (s *watchTestSuite) Test_Watch_Errors(c *C) {
+	for path, status := range map[string]int{
+		"/watch/missing.json":          http.StatusNotFound,
+		"/watch/app.json?patch=maybe":  http.StatusBadRequest,
+		"/watch/app.json?timeout=-1s":  http.StatusBadRequest,
+		"/watch/app.json?format=xml":   http.StatusBadRequest,
+		"/watch/../../etc/passwd.json": http.StatusNotFound,
+	} {
+		resp := s.get(c, path, nil)
+		resp.Body.Close()
+		c.Assert(resp.StatusCode, Equals, status, Commentf(path))
+	}
+
+	s.write(c, "parent.json", `{`)
+	resp := s.get(c, "/watch/app.json", nil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, Equals, http.StatusUnprocessableEntity)
+}
+
+// event reads the next Server-Sent Event.
+func event(c *C, r *bufio.Reader) (string, string, string) {
+	var name, id, data string
+	for {
+		line, err := r.ReadString('\n')
+		c.Assert(err, IsNil)
+		line = strings.TrimRight(line, "\n")
+
+		switch {
+		case line == "":
+			return name, id, data
+		case strings.HasPrefix(line, "event: "):
+			name = line[7:]
+		case strings.HasPrefix(line, "id: "):
+			id = line[4:]
+		case strings.HasPrefix(line, "data: "):
+			data = line[6:]
+		}
+	}
+}
+
+func (s *watchTestSuite) Test_Watch_Events(c *C) {
+	resp := s.get(c, "/watch/app.json?patch=1", map[string]string{"Accept": "text/event-stream"})
+	defer resp.Body.Close()
+	c.Assert(resp.StatusCode, Equals, http.StatusOK)
+	c.Assert(resp.Header.Get("Content-Type"), Equals, "text/event-stream")
+
+	r := bufio.NewReader(resp.Body)
+
+	name, etag, data := event(c, r)
+	c.Assert(name, Equals, "change")
+	c.Assert(data, Equals, `{"etag":`+strconv.Quote(etag)+`}`)
+
+	s.write(c, "app.json", `{"@parent": {"$ref": "parent.json"}, "name": "web"}`)
+	name, id, data := event(c, r)
+	c.Assert(name, Equals, "change")
+	c.Assert(id, Not(Equals), etag)
+
+	ch := &change{}
+	c.Assert(json.Unmarshal([]byte(data), ch), IsNil)
+	c.Assert(ch.ETag, Equals, id)
+	c.Assert(ch.Patch, DeepEquals, []map[string]interface{}{{"op": "replace", "path": "/name", "value": "web"}})
+
+	// The broken config is told once, the previous version is kept for the patch.
+	s.write(c, "parent.json", `{`)
+	name, _, data = event(c, r)
+	c.Assert(name, Equals, "failure")
+	c.Assert(data, Matches, `.*"code":"invalid_document".*`)
+
+	s.write(c, "parent.json", `{"port": 8080, "debug": true}`)
+	name, _, data = event(c, r)
+	c.Assert(name, Equals, "change")
+	c.Assert(json.Unmarshal([]byte(data), ch), IsNil)
+	c.Assert(ch.Patch, DeepEquals, []map[string]interface{}{{"op": "add", "path": "/debug", "value": true}})
+
+	// The config which is fixed back to the previous version is told too.
+	s.write(c, "parent.json", `{`)
+	name, _, _ = event(c, r)
+	c.Assert(name, Equals, "failure")
+
+	s.write(c, "parent.json", `{"port": 8080, "debug": true}`)
+	name, id, data = event(c, r)
+	c.Assert(name, Equals, "change")
+	c.Assert(id, Equals, ch.ETag)
+	c.Assert(data, Equals, `{"etag":`+strconv.Quote(ch.ETag)+`}`)
+
+	// The known version isn't sent again.
+	resp2 := s.get(c, "/watch/app.json", map[string]string{"Accept": "text/event-stream", "Last-Event-ID": ch.ETag})
+	defer resp2.Body.Close()
+
+	r2 := bufio.NewReader(resp2.Body)
+	s.write(c, "app.json", `{"@parent": {"$ref": "parent.json"}, "name": "app"}`)
+	name, id, _ = event(c, r2)
+	c.Assert(name, Equals, "change")
+	c.Assert(id, Not(Equals), ch.ETag)
+}
+
+func (s *watchTestSuite) Test_Watch_Shutdown(c *C) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	addr := l.Addr().String()
+	l.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- httpserver.Run(ctx, httpserver.Config{Addr: addr, RootDir: s.root, ShutdownTimeout: time.Minute})
+	}()
+
+	var resp *http.Response
+	for i := 0; i < 100; i++ {
+		req, _ := http.NewRequest("GET", "http://"+addr+"/watch/app.json", nil)
+		req.Header.Set("Accept", "text/event-stream")
+		if resp, err = http.DefaultClient.Do(req); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Assert(err, IsNil)
+	defer resp.Body.Close()
+
+	name, _, _ := event(c, bufio.NewReader(resp.Body))
+	c.Assert(name, Equals, "change")
+
+	start := time.Now()
+	cancel()
+	c.Assert(<-done, IsNil)
+	c.Assert(time.Since(start) < 10*time.Second, Equals, true)
+}
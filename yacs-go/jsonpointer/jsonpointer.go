@@ -0,0 +1,335 @@
+package jsonpointer
+
+/*
+
+Implements JSON Pointer: https://tools.ietf.org/html/rfc6901
+
+Example usage:
+
+	p, err := jsonpointer.Parse("/servers/0/port")
+	if err != nil {
+		return err
+	}
+
+	port, err := jsonpointer.Get(doc, p)
+	if _, ok := err.(*jsonpointer.NotFoundError); ok {
+		// "/servers/0/port" doesn't exist, it's not the same as "port": null
+	}
+
+	doc, err = jsonpointer.Set(doc, p, 8080.0)
+
+Documents are trees of map[string]interface{}, []interface{} and scalars like encoding/json makes.
+
+*/
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+var (
+	escaper   = strings.NewReplacer("~", "~0", "/", "~1")
+	unescaper = strings.NewReplacer("~1", "/", "~0", "~")
+)
+
+// Pointer is a parsed JSON Pointer. The empty Pointer refers to the whole document.
+type Pointer []string
+
+// SyntaxError is returned by Parse for malformed pointers.
+type SyntaxError struct {
+	Pointer string
+	Reason  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("invalid JSON pointer '%s': %s", e.Pointer, e.Reason)
+}
+
+// NotFoundError is returned when the pointer refers to a missing value.
+type NotFoundError struct {
+	// Pointer is the full pointer, Missing is its prefix which doesn't exist.
+	Pointer string
+	Missing string
+}
+
+func (e *NotFoundError) Error() string {
+	if e.Pointer == e.Missing {
+		return fmt.Sprintf("JSON pointer '%s' is not found", e.Pointer)
+	}
+	return fmt.Sprintf("JSON pointer '%s' is not found: '%s' doesn't exist", e.Pointer, e.Missing)
+}
+
+// Escape escapes "~" and "/" in the reference token.
+func Escape(token string) string {
+	return escaper.Replace(token)
+}
+
+// Parse parses pointer like "/a/b~1c/0".
+func Parse(s string) (Pointer, error) {
+
+	if s == "" {
+		return Pointer{}, nil
+	}
+
+	if !strings.HasPrefix(s, "/") {
+		return nil, &SyntaxError{Pointer: s, Reason: "it must start with '/'"}
+	}
+
+	tokens := strings.Split(s[1:], "/")
+	for i, t := range tokens {
+		if err := checkEscapes(t); err != nil {
+			return nil, &SyntaxError{Pointer: s, Reason: err.Error()}
+		}
+		tokens[i] = unescaper.Replace(t)
+	}
+
+	return Pointer(tokens), nil
+}
+
+// ParseFragment parses pointer from URI fragment like "#/a%20b/0". The "#" is optional.
+func ParseFragment(fragment string) (Pointer, error) {
+
+	s, err := url.PathUnescape(strings.TrimPrefix(fragment, "#"))
+	if err != nil {
+		return nil, &SyntaxError{Pointer: fragment, Reason: err.Error()}
+	}
+
+	return Parse(s)
+}
+
+// MustParse is like Parse but panics on error. It is used for constants.
+func MustParse(s string) Pointer {
+	p, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func checkEscapes(token string) error {
+	for i := 0; i < len(token); i++ {
+		if token[i] != '~' {
+			continue
+		}
+		if i+1 == len(token) || (token[i+1] != '0' && token[i+1] != '1') {
+			return fmt.Errorf("bad escape sequence at %d", i)
+		}
+	}
+	return nil
+}
+
+func (p Pointer) String() string {
+	var b strings.Builder
+	for _, t := range p {
+		b.WriteString("/")
+		b.WriteString(Escape(t))
+	}
+	return b.String()
+}
+
+// Fragment returns the pointer as URI fragment without "#".
+func (p Pointer) Fragment() string {
+	u := url.URL{Fragment: p.String()}
+	return strings.TrimPrefix(u.String(), "#")
+}
+
+// Append returns new pointer with added tokens.
+func (p Pointer) Append(tokens ...string) Pointer {
+	out := make(Pointer, 0, len(p)+len(tokens))
+	out = append(out, p...)
+	return append(out, tokens...)
+}
+
+// Parent returns pointer without the last token. The parent of the empty pointer is the empty pointer.
+func (p Pointer) Parent() Pointer {
+	if len(p) == 0 {
+		return p
+	}
+	return p[:len(p)-1]
+}
+
+// Last returns the last token or "".
+func (p Pointer) Last() string {
+	if len(p) == 0 {
+		return ""
+	}
+	return p[len(p)-1]
+}
+
+// HasPrefix checks that p is equal to prefix or refers inside it.
+func (p Pointer) HasPrefix(prefix Pointer) bool {
+	if len(prefix) > len(p) {
+		return false
+	}
+	for i := range prefix {
+		if p[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// arrayIndex converts token to index. "-" refers after the last element.
+func arrayIndex(token string, length int) (int, bool) {
+
+	if token == "-" {
+		return length, true
+	}
+
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, false
+	}
+
+	for _, r := range token {
+		if r < '0' || r > '9' {
+			return 0, false
+		}
+	}
+
+	i, err := strconv.Atoi(token)
+	return i, err == nil
+}
+
+func (p Pointer) notFound(i int) error {
+	return &NotFoundError{Pointer: p.String(), Missing: p[:i+1].String()}
+}
+
+// Get returns value by pointer. A missing value is *NotFoundError, null is (nil, nil).
+func Get(doc interface{}, p Pointer) (interface{}, error) {
+
+	node := doc
+	for i, token := range p {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			next, find := n[token]
+			if !find {
+				return nil, p.notFound(i)
+			}
+			node = next
+
+		case []interface{}:
+			idx, ok := arrayIndex(token, len(n))
+			if !ok || idx >= len(n) {
+				return nil, p.notFound(i)
+			}
+			node = n[idx]
+
+		default:
+			return nil, p.notFound(i)
+		}
+	}
+
+	return node, nil
+}
+
+// Has checks that the value exists.
+func Has(doc interface{}, p Pointer) bool {
+	_, err := Get(doc, p)
+	return err == nil
+}
+
+// Set sets value by pointer and returns new document.
+// The parent of the value must exist. For arrays, "-" or the length of array appends the value.
+func Set(doc interface{}, p Pointer, value interface{}) (interface{}, error) {
+	return set(doc, p, 0, value)
+}
+
+func set(node interface{}, p Pointer, i int, value interface{}) (interface{}, error) {
+
+	if i == len(p) {
+		return value, nil
+	}
+
+	token := p[i]
+	last := i == len(p)-1
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		next, find := n[token]
+		if !find && !last {
+			return nil, p.notFound(i)
+		}
+
+		res, err := set(next, p, i+1, value)
+		if err != nil {
+			return nil, err
+		}
+		n[token] = res
+		return n, nil
+
+	case []interface{}:
+		idx, ok := arrayIndex(token, len(n))
+		if !ok || idx > len(n) || (idx == len(n) && !last) {
+			return nil, p.notFound(i)
+		}
+
+		if idx == len(n) {
+			return append(n, value), nil
+		}
+
+		res, err := set(n[idx], p, i+1, value)
+		if err != nil {
+			return nil, err
+		}
+		n[idx] = res
+		return n, nil
+	}
+
+	return nil, p.notFound(i)
+}
+
+// Delete removes value by pointer and returns new document.
+// Deleting of the whole document returns nil.
+func Delete(doc interface{}, p Pointer) (interface{}, error) {
+	if len(p) == 0 {
+		return nil, nil
+	}
+	return del(doc, p, 0)
+}
+
+func del(node interface{}, p Pointer, i int) (interface{}, error) {
+
+	token := p[i]
+	last := i == len(p)-1
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		next, find := n[token]
+		if !find {
+			return nil, p.notFound(i)
+		}
+
+		if last {
+			delete(n, token)
+			return n, nil
+		}
+
+		res, err := del(next, p, i+1)
+		if err != nil {
+			return nil, err
+		}
+		n[token] = res
+		return n, nil
+
+	case []interface{}:
+		idx, ok := arrayIndex(token, len(n))
+		if !ok || idx >= len(n) {
+			return nil, p.notFound(i)
+		}
+
+		if last {
+			return append(n[:idx:idx], n[idx+1:]...), nil
+		}
+
+		res, err := del(n[idx], p, i+1)
+		if err != nil {
+			return nil, err
+		}
+		n[idx] = res
+		return n, nil
+	}
+
+	return nil, p.notFound(i)
+}
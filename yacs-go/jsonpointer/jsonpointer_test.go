@@ -0,0 +1,175 @@
+package jsonpointer
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func Test(t *testing.T) { TestingT(t) }
+
+type jsonPointerTestSuite struct{}
+
+var _ = Suite(&jsonPointerTestSuite{})
+
+// rfcDoc is the example from RFC 6901, section 5.
+func rfcDoc(c *C) interface{} {
+	var doc interface{}
+	err := json.Unmarshal([]byte(`{
+		"foo": ["bar", "baz"],
+		"": 0,
+		"a/b": 1,
+		"c%d": 2,
+		"e^f": 3,
+		"g|h": 4,
+		"i\\j": 5,
+		"k\"l": 6,
+		" ": 7,
+		"m~n": 8,
+		"null": null
+	}`), &doc)
+	c.Assert(err, IsNil)
+	return doc
+}
+
+func (s *jsonPointerTestSuite) Test_Get_RFC(c *C) {
+	doc := rfcDoc(c)
+
+	cases := map[string]interface{}{
+		"/foo/0": "bar",
+		"/":      float64(0),
+		"/a~1b":  float64(1),
+		"/c%d":   float64(2),
+		"/e^f":   float64(3),
+		"/g|h":   float64(4),
+		"/i\\j":  float64(5),
+		"/k\"l":  float64(6),
+		"/ ":     float64(7),
+		"/m~0n":  float64(8),
+	}
+
+	for str, expected := range cases {
+		p, err := Parse(str)
+		c.Assert(err, IsNil)
+		c.Assert(p.String(), Equals, str)
+
+		res, err := Get(doc, p)
+		c.Assert(err, IsNil, Commentf(str))
+		c.Assert(res, Equals, expected, Commentf(str))
+	}
+
+	res, err := Get(doc, Pointer{})
+	c.Assert(err, IsNil)
+	c.Assert(res, DeepEquals, doc)
+}
+
+func (s *jsonPointerTestSuite) Test_ParseFragment(c *C) {
+	doc := rfcDoc(c)
+
+	cases := map[string]interface{}{
+		"#/foo/1": "baz",
+		"#/c%25d": float64(2),
+		"#/%20":   float64(7),
+		"#/m~0n":  float64(8),
+		"/a~1b":   float64(1),
+	}
+
+	for str, expected := range cases {
+		p, err := ParseFragment(str)
+		c.Assert(err, IsNil)
+
+		res, err := Get(doc, p)
+		c.Assert(err, IsNil, Commentf(str))
+		c.Assert(res, Equals, expected, Commentf(str))
+	}
+
+	c.Assert(MustParse("/c%d/a b").Fragment(), Equals, "/c%25d/a%20b")
+}
+
+func (s *jsonPointerTestSuite) Test_Parse_Errors(c *C) {
+	for _, str := range []string{"foo", "/a~", "/a~2b", "#/a"} {
+		_, err := Parse(str)
+		_, ok := err.(*SyntaxError)
+		c.Assert(ok, Equals, true, Commentf(str))
+	}
+}
+
+func (s *jsonPointerTestSuite) Test_Get_Missing(c *C) {
+	doc := rfcDoc(c)
+
+	// null is not the same as missing value.
+	res, err := Get(doc, MustParse("/null"))
+	c.Assert(err, IsNil)
+	c.Assert(res, IsNil)
+
+	for _, str := range []string{"/missing", "/foo/2", "/foo/-", "/foo/01", "/foo/bar", "/null/x", "/foo/0/x"} {
+		_, err := Get(doc, MustParse(str))
+		nf, ok := err.(*NotFoundError)
+		c.Assert(ok, Equals, true, Commentf(str))
+		c.Assert(nf.Pointer, Equals, str)
+	}
+
+	_, err = Get(doc, MustParse("/missing/a/b"))
+	c.Assert(err, ErrorMatches, "JSON pointer '/missing/a/b' is not found: '/missing' doesn't exist")
+	c.Assert(Has(doc, MustParse("/foo/1")), Equals, true)
+}
+
+func (s *jsonPointerTestSuite) Test_Set(c *C) {
+	doc := rfcDoc(c)
+
+	doc, err := Set(doc, MustParse("/foo/1"), "qux")
+	c.Assert(err, IsNil)
+	doc, err = Set(doc, MustParse("/foo/-"), "end")
+	c.Assert(err, IsNil)
+	doc, err = Set(doc, MustParse("/new"), map[string]interface{}{})
+	c.Assert(err, IsNil)
+	doc, err = Set(doc, MustParse("/new/a~1b"), true)
+	c.Assert(err, IsNil)
+
+	res, _ := Get(doc, MustParse("/foo"))
+	c.Assert(res, DeepEquals, []interface{}{"bar", "qux", "end"})
+	res, _ = Get(doc, MustParse("/new"))
+	c.Assert(res, DeepEquals, map[string]interface{}{"a/b": true})
+
+	_, err = Set(doc, MustParse("/x/y"), 1)
+	_, ok := err.(*NotFoundError)
+	c.Assert(ok, Equals, true)
+
+	_, err = Set(doc, MustParse("/foo/9"), 1)
+	_, ok = err.(*NotFoundError)
+	c.Assert(ok, Equals, true)
+
+	res, err = Set(doc, Pointer{}, "root")
+	c.Assert(err, IsNil)
+	c.Assert(res, Equals, "root")
+}
+
+func (s *jsonPointerTestSuite) Test_Delete(c *C) {
+	doc := rfcDoc(c)
+
+	doc, err := Delete(doc, MustParse("/foo/0"))
+	c.Assert(err, IsNil)
+	doc, err = Delete(doc, MustParse("/a~1b"))
+	c.Assert(err, IsNil)
+
+	res, _ := Get(doc, MustParse("/foo"))
+	c.Assert(res, DeepEquals, []interface{}{"baz"})
+	c.Assert(Has(doc, MustParse("/a~1b")), Equals, false)
+
+	_, err = Delete(doc, MustParse("/a~1b"))
+	_, ok := err.(*NotFoundError)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *jsonPointerTestSuite) Test_Helpers(c *C) {
+	p := MustParse("/a/b")
+	c.Assert(p.Append("c~").String(), Equals, "/a/b/c~0")
+	c.Assert(p.String(), Equals, "/a/b")
+	c.Assert(p.Parent().String(), Equals, "/a")
+	c.Assert(p.Last(), Equals, "b")
+	c.Assert(p.HasPrefix(MustParse("/a")), Equals, true)
+	c.Assert(p.HasPrefix(MustParse("/b")), Equals, false)
+	c.Assert(Pointer{}.Parent(), HasLen, 0)
+}
@@ -34,106 +34,186 @@ Example usage:
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/xeipuuv/gojsonschema"
 
+	"github.com/iostrovok/yacs-go/yacs-go/jsonpointer"
 	"github.com/iostrovok/yacs-go/yacs-go/myconst"
 	"github.com/iostrovok/yacs-go/yacs-go/utils"
 )
 
-func joinErrors(errors []error) error {
-	if len(errors) == 0 {
-		return nil
-	}
+// Failure is a single violation of a schema from "@schemas".
+type Failure struct {
+	// SchemaKey is the key of the schema in "@schemas".
+	SchemaKey string
+	// Pointer is JSON pointer of the validated subtree, "" is the whole document.
+	Pointer string
+	// Path is JSON pointer of the invalid value.
+	Path string
+	// Field, Type, Description and Details are taken from gojsonschema error.
+	// Type is "schema" if the schema itself can not be used.
+	Field       string
+	Type        string
+	Description string
+	Details     gojsonschema.ErrorDetails
+	// URI, Line and Column are the place of the invalid value in the source document if it's known.
+	URI    string
+	Line   int
+	Column int
+}
 
-	strs := []string{}
-	for i, e := range errors {
-		strs = append(strs, fmt.Sprintf("[%d] %s", i, e))
+func (f Failure) String() string {
+	msg := fmt.Sprintf("schema '%s' at '%s': %s: %s", f.SchemaKey, f.Pointer, f.Field, f.Description)
+	if f.Line > 0 {
+		return fmt.Sprintf("%s:%d:%d: %s", f.URI, f.Line, f.Column, msg)
 	}
+	return msg
+}
 
-	return fmt.Errorf(strings.Join(strs, "\n"))
+// ValidationError is the list of all schema violations of the document.
+type ValidationError []Failure
+
+func (ve ValidationError) Error() string {
+	strs := []string{fmt.Sprintf("the document is not valid, %d error(s):", len(ve))}
+	for i, f := range ve {
+		strs = append(strs, fmt.Sprintf("[%d] %s", i, f))
+	}
+	return strings.Join(strs, "\n")
 }
 
-// ValidateSchema validates json schema
-func ValidateSchema(doc interface{}, verbose bool) (interface{}, error) {
-	out, errs := validateListSchemas(doc, verbose)
-	return out, joinErrors(errs)
+// ValidateSchema validates doc against all "@schemas" and removes them.
+// It returns ValidationError if the doc doesn't match any schema.
+func ValidateSchema(doc interface{}, logger utils.Logger) (interface{}, error) {
+	out, failures := validateListSchemas(doc, "", logger)
+	if len(failures) > 0 {
+		return out, failures
+	}
+	return out, nil
 }
 
-func validateListSchemas(doc interface{}, verbose bool) (interface{}, []error) {
+func validateListSchemas(doc interface{}, pointer string, logger utils.Logger) (interface{}, ValidationError) {
 
-	var e []error
+	var failures, f ValidationError
 
 	switch doc.(type) {
 	case map[string]interface{}:
-		out, errors := validateAgainstSchemas(doc.(map[string]interface{}), verbose)
-		for key, value := range doc.(map[string]interface{}) {
-			out[key], e = validateListSchemas(value, verbose)
-			errors = append(errors, e...)
+		m := doc.(map[string]interface{})
+		schemas, find := m[myconst.SchemaKeyName]
+		delete(m, myconst.SchemaKeyName)
+
+		// Nested "@schemas" are removed before the validation of this level.
+		for _, key := range sortedKeys(m) {
+			m[key], f = validateListSchemas(m[key], pointer+"/"+jsonpointer.Escape(key), logger)
+			failures = append(failures, f...)
 		}
-		return out, errors
+
+		if find {
+			failures = append(failures, validateAgainstSchemas(schemas, m, pointer, logger)...)
+		}
+		return m, failures
 
 	case []interface{}:
-		out := make([]interface{}, len(doc.([]interface{})))
-		errors := []error{}
-		for i, value := range doc.([]interface{}) {
-			out[i], e = validateListSchemas(value, verbose)
-			errors = append(errors, e...)
+		out := doc.([]interface{})
+		for i := range out {
+			out[i], f = validateListSchemas(out[i], fmt.Sprintf("%s/%d", pointer, i), logger)
+			failures = append(failures, f...)
 		}
-		return out, errors
+		return out, failures
 	}
-	return doc, []error{}
+	return doc, failures
 }
 
-func validateAgainstSchemas(doc map[string]interface{}, verbose bool) (map[string]interface{}, []error) {
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
 
-	out := []error{}
+func validateAgainstSchemas(schemas interface{}, doc map[string]interface{}, pointer string, logger utils.Logger) ValidationError {
 
-	schemas, find := utils.GetKeyFromInteface(doc, myconst.SchemaKeyName)
-	if !find {
-		return doc, out
+	list, ok := schemas.(map[string]interface{})
+	if !ok {
+		return ValidationError{{
+			Pointer:     pointer,
+			Path:        pointer,
+			Type:        "schema",
+			Description: fmt.Sprintf("'%s' must be an object", myconst.SchemaKeyName),
+		}}
 	}
 
-	delete(doc, myconst.SchemaKeyName)
-
-	switch schemas.(type) {
-	case map[string]interface{}:
-		for _, schema := range schemas.(map[string]interface{}) {
-			switch schema.(type) {
-			case map[string]interface{}:
-				validateOneSchema(schema.(map[string]interface{}), doc, verbose)
-			}
-		}
+	failures := ValidationError{}
+	for _, key := range sortedKeys(list) {
+		failures = append(failures, validateOneSchema(key, list[key], doc, pointer, logger)...)
 	}
 
-	return doc, out
+	return failures
 }
 
-func validateOneSchema(schema, body map[string]interface{}, verbose bool) error {
+func validateOneSchema(key string, schema interface{}, body map[string]interface{}, pointer string, logger utils.Logger) ValidationError {
+
+	schemaError := func(description string) ValidationError {
+		return ValidationError{{
+			SchemaKey:   key,
+			Pointer:     pointer,
+			Path:        pointer,
+			Type:        "schema",
+			Description: description,
+		}}
+	}
+
+	if !utils.IsMapStringInterface(schema) {
+		return schemaError("schema must be an object")
+	}
 
 	schemaLoader := gojsonschema.NewGoLoader(schema)
 	documentLoader := gojsonschema.NewGoLoader(body)
 	result, err := gojsonschema.Validate(schemaLoader, documentLoader)
 	if err != nil {
-		return err
+		return schemaError(err.Error())
 	}
 
 	if result.Valid() {
-		if verbose {
-			fmt.Printf("The document is valid\n")
+		if logger != nil {
+			logger.Printf("The document '%s' is valid against schema '%s'", pointer, key)
 		}
 		return nil
 	}
 
-	out := "The document is not valid. see errors :\n"
-	out += fmt.Sprintf("schema: %v\n", schema)
-
+	failures := ValidationError{}
 	for _, desc := range result.Errors() {
-		out += fmt.Sprintf("- %s\n", desc)
+		failures = append(failures, Failure{
+			SchemaKey:   key,
+			Pointer:     pointer,
+			Path:        pointer + contextPath(desc.Context()),
+			Field:       desc.Field(),
+			Type:        desc.Type(),
+			Description: desc.Description(),
+			Details:     desc.Details(),
+		})
 	}
 
-	return fmt.Errorf(out)
+	return failures
+}
+
+// contextDelimiter splits gojsonschema context into keys, "." and "/" may be parts of keys.
+const contextDelimiter = "\x00"
+
+// contextPath converts gojsonschema context like "(root).a.b" to JSON pointer "/a/b", keys are escaped.
+func contextPath(context *gojsonschema.JsonContext) string {
+	if context == nil {
+		return ""
+	}
+
+	tokens := strings.Split(context.String(contextDelimiter), contextDelimiter)
+	if tokens[0] == gojsonschema.STRING_CONTEXT_ROOT {
+		tokens = tokens[1:]
+	}
+	return jsonpointer.Pointer(tokens).String()
 }
 
 // RemoveSchemaReferences - removes "@schemas" objects from JSON without validation.
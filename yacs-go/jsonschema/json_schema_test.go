@@ -0,0 +1,113 @@
+package jsonschema
+
+import (
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func Test(t *testing.T) { TestingT(t) }
+
+type jsonSchemaTestSuite struct{}
+
+var _ = Suite(&jsonSchemaTestSuite{})
+
+func userSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"$schema":  "http://json-schema.org/draft-04/schema#",
+		"type":     "object",
+		"required": []interface{}{"username"},
+		"properties": map[string]interface{}{
+			"username": map[string]interface{}{"type": "string"},
+		},
+	}
+}
+
+func (s *jsonSchemaTestSuite) Test_ValidateSchema_Valid(c *C) {
+	doc := map[string]interface{}{
+		"@schemas": map[string]interface{}{"user": userSchema()},
+		"username": "web",
+	}
+
+	out, err := ValidateSchema(doc, nil)
+	c.Assert(err, IsNil)
+	c.Assert(out, DeepEquals, map[string]interface{}{"username": "web"})
+}
+
+func (s *jsonSchemaTestSuite) Test_ValidateSchema_Invalid(c *C) {
+	doc := map[string]interface{}{
+		"servers": []interface{}{
+			map[string]interface{}{
+				"@schemas": map[string]interface{}{"user": userSchema()},
+				"username": 10.0,
+			},
+		},
+		"db/main": map[string]interface{}{
+			"@schemas": map[string]interface{}{"user": userSchema()},
+		},
+	}
+
+	_, err := ValidateSchema(doc, nil)
+	c.Assert(err, NotNil)
+
+	failures, ok := err.(ValidationError)
+	c.Assert(ok, Equals, true)
+	c.Assert(failures, HasLen, 2)
+
+	c.Assert(failures[0].SchemaKey, Equals, "user")
+	c.Assert(failures[0].Pointer, Equals, "/db~1main")
+	c.Assert(failures[0].Type, Equals, "required")
+	c.Assert(failures[0].Details["property"], Equals, "username")
+
+	c.Assert(failures[1].Pointer, Equals, "/servers/0")
+	c.Assert(failures[1].Path, Equals, "/servers/0/username")
+	c.Assert(failures[1].Type, Equals, "invalid_type")
+}
+
+func (s *jsonSchemaTestSuite) Test_ValidateSchema_EscapedPath(c *C) {
+	doc := map[string]interface{}{
+		"@schemas": map[string]interface{}{"keys": map[string]interface{}{
+			"properties": map[string]interface{}{
+				"a/b": map[string]interface{}{
+					"properties": map[string]interface{}{
+						"x~y.z": map[string]interface{}{"type": "string"},
+					},
+				},
+			},
+		}},
+		"a/b": map[string]interface{}{"x~y.z": 1.0},
+	}
+
+	_, err := ValidateSchema(doc, nil)
+	failures, ok := err.(ValidationError)
+	c.Assert(ok, Equals, true)
+	c.Assert(failures, HasLen, 1)
+	c.Assert(failures[0].Path, Equals, "/a~1b/x~0y.z")
+}
+
+func (s *jsonSchemaTestSuite) Test_ValidateSchema_BadSchema(c *C) {
+	doc := map[string]interface{}{
+		"@schemas": map[string]interface{}{"user": "user.json"},
+	}
+
+	_, err := ValidateSchema(doc, nil)
+	failures, ok := err.(ValidationError)
+	c.Assert(ok, Equals, true)
+	c.Assert(failures, HasLen, 1)
+	c.Assert(failures[0].Type, Equals, "schema")
+}
+
+func (s *jsonSchemaTestSuite) Test_RemoveSchemaReferences(c *C) {
+	doc := map[string]interface{}{
+		"@schemas": map[string]interface{}{"user": userSchema()},
+		"list": []interface{}{
+			map[string]interface{}{"@schemas": map[string]interface{}{}, "a": "b"},
+		},
+	}
+
+	out := RemoveSchemaReferences(doc)
+	c.Assert(out, DeepEquals, map[string]interface{}{
+		"list": []interface{}{map[string]interface{}{"a": "b"}},
+	})
+}
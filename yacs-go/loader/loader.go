@@ -1,60 +1,362 @@
 package loader
 
 import (
-	"encoding/json"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
+
+	"github.com/iostrovok/yacs-go/yacs-go/format"
+	"github.com/iostrovok/yacs-go/yacs-go/utils"
 )
 
-// GetURI returns json parsed object by URL or file link.
-func GetURI(filename string) (interface{}, error) {
+// Resource is a raw document which is fetched by Loader.
+type Resource struct {
+	URI         string
+	Body        []byte
+	ContentType string
+	// Version is mtime and size of file or ETag of URL. Empty string means unknown version.
+	Version string
+}
 
-	var body []byte
-	var err error
+// Loader fetches raw documents by URI. Each loader serves one or more URI schemes.
+type Loader interface {
+	Load(uri string) (*Resource, error)
+}
 
-	if isURL(filename) {
-		body, err = getURL(filename)
-	} else {
-		body, err = loadFile(filename)
+// Versioner is implemented by loaders which can tell the version of document without loading it.
+type Versioner interface {
+	Version(uri string) (string, error)
+}
+
+// SizeLimiter is implemented by loaders which stop reading of documents larger than limit.
+// Zero limit means no limit.
+type SizeLimiter interface {
+	LoadLimited(uri string, limit int64) (*Resource, error)
+}
+
+// TooLargeError is returned when the document is larger than the limit.
+type TooLargeError struct {
+	URI   string
+	Limit int64
+}
+
+func (e *TooLargeError) Error() string {
+	return fmt.Sprintf("%s: document is larger than %d bytes", e.URI, e.Limit)
+}
+
+// ReadLimited reads r, it fails as soon as more than limit bytes are read. Zero limit means no limit.
+func ReadLimited(uri string, r io.Reader, limit int64) ([]byte, error) {
+	if limit <= 0 {
+		return ioutil.ReadAll(r)
 	}
 
+	body, err := ioutil.ReadAll(io.LimitReader(r, limit+1))
 	if err != nil {
 		return nil, err
 	}
-
-	var s interface{}
-	err = json.Unmarshal(body, &s)
-	return s, err
+	if int64(len(body)) > limit {
+		return nil, &TooLargeError{URI: uri, Limit: limit}
+	}
+	return body, nil
 }
 
-func loadFile(filename string) ([]byte, error) {
+// FileLoader loads documents from the local file system.
+type FileLoader struct{}
 
-	filename = strings.TrimPrefix(filename, "file://")
+// Load reads file. The "file://" prefix is optional.
+func (FileLoader) Load(uri string) (*Resource, error) {
+	return FileLoader{}.LoadLimited(uri, 0)
+}
 
-	file, err := os.Open(filename)
+// LoadLimited reads file up to limit bytes.
+func (FileLoader) LoadLimited(uri string, limit int64) (*Resource, error) {
+	version, err := FileLoader{}.Version(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := loadFile(uri, limit)
 	if err != nil {
 		return nil, err
 	}
 
-	return ioutil.ReadAll(file)
+	return &Resource{URI: uri, Body: body, Version: version}, nil
+}
+
+// Version returns mtime and size of file.
+func (FileLoader) Version(uri string) (string, error) {
+	fi, err := os.Stat(strings.TrimPrefix(uri, "file://"))
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%d-%d", fi.ModTime().UnixNano(), fi.Size()), nil
 }
 
-func getURL(filename string) ([]byte, error) {
+// DirLoader loads local files inside of Dir only. Paths out of Dir, absolute or with "..",
+// and symlinks which lead out of Dir are errors.
+type DirLoader struct {
+	Dir string
+}
 
-	resp, err := http.Get(filename)
+// Load reads file inside of Dir. The "file://" prefix is optional.
+func (l DirLoader) Load(uri string) (*Resource, error) {
+	return l.LoadLimited(uri, 0)
+}
+
+// LoadLimited reads file inside of Dir up to limit bytes.
+func (l DirLoader) LoadLimited(uri string, limit int64) (*Resource, error) {
+	file, err := l.path(uri)
 	if err != nil {
 		return nil, err
 	}
+	return FileLoader{}.LoadLimited(file, limit)
+}
+
+// Version returns mtime and size of file inside of Dir.
+func (l DirLoader) Version(uri string) (string, error) {
+	file, err := l.path(uri)
+	if err != nil {
+		return "", err
+	}
+	return FileLoader{}.Version(file)
+}
+
+// path returns the real path of the file, it checks the path before and after symlinks are followed,
+// so files out of Dir are never touched.
+func (l DirLoader) path(uri string) (string, error) {
+
+	dir, err := filepath.Abs(l.Dir)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := filepath.Abs(strings.TrimPrefix(uri, "file://"))
+	if err != nil {
+		return "", err
+	}
+
+	if !utils.IsWithinDir(file, dir) {
+		return "", fmt.Errorf("%s: the file is out of the root dir", uri)
+	}
+
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		return "", err
+	}
+	if file, err = filepath.EvalSymlinks(file); err != nil {
+		return "", err
+	}
+
+	if !utils.IsWithinDir(file, dir) {
+		return "", fmt.Errorf("%s: the file is out of the root dir", uri)
+	}
+
+	return file, nil
+}
+
+// DefaultHTTPTimeout is used by HTTPLoader without own client.
+const DefaultHTTPTimeout = 30 * time.Second
+
+// HTTPLoader loads documents by http and https URLs.
+type HTTPLoader struct {
+	Client *http.Client
+	// Header is added to every request.
+	Header http.Header
+}
+
+// NewHTTPLoader returns loader with timeout of requests and additional headers.
+func NewHTTPLoader(timeout time.Duration, header http.Header) *HTTPLoader {
+	return &HTTPLoader{
+		Client: &http.Client{Timeout: timeout},
+		Header: header,
+	}
+}
 
+// Load sends GET request. Any status except 2xx is an error.
+func (l *HTTPLoader) Load(uri string) (*Resource, error) {
+	return l.LoadLimited(uri, 0)
+}
+
+// LoadLimited sends GET request and reads the response up to limit bytes.
+func (l *HTTPLoader) LoadLimited(uri string, limit int64) (*Resource, error) {
+
+	resp, err := l.do(http.MethodGet, uri)
+	if err != nil {
+		return nil, err
+	}
 	defer resp.Body.Close()
-	return ioutil.ReadAll(resp.Body)
+
+	body, err := ReadLimited(uri, resp.Body, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Resource{
+		URI:         uri,
+		Body:        body,
+		ContentType: resp.Header.Get("Content-Type"),
+		Version:     httpVersion(resp),
+	}, nil
+}
+
+// Version sends HEAD request and returns ETag or Last-Modified header.
+func (l *HTTPLoader) Version(uri string) (string, error) {
+
+	resp, err := l.do(http.MethodHead, uri)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	return httpVersion(resp), nil
+}
+
+func (l *HTTPLoader) do(method, uri string) (*http.Response, error) {
+
+	req, err := http.NewRequest(method, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, values := range l.Header {
+		for _, v := range values {
+			req.Header.Add(key, v)
+		}
+	}
+
+	client := l.Client
+	if client == nil {
+		client = &http.Client{Timeout: DefaultHTTPTimeout}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s %s: %s", method, uri, resp.Status)
+	}
+
+	return resp, nil
+}
+
+// HostsLoader loads URLs of allowed hosts only, redirects to other hosts are errors too.
+// No hosts means no remote documents at all.
+type HostsLoader struct {
+	http  *HTTPLoader
+	hosts map[string]bool
+}
+
+// NewHostsLoader returns loader of URLs of hosts by l. Hosts are names, e.g. "example.com",
+// or names with ports, e.g. "example.com:8080". The client of l is copied, so l isn't changed.
+func NewHostsLoader(l *HTTPLoader, hosts ...string) *HostsLoader {
+
+	h := &HostsLoader{hosts: map[string]bool{}}
+	for _, host := range hosts {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			h.hosts[host] = true
+		}
+	}
+
+	client := &http.Client{Timeout: DefaultHTTPTimeout}
+	if l.Client != nil {
+		c := *l.Client
+		client = &c
+	}
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return fmt.Errorf("stopped after 10 redirects")
+		}
+		return h.check(req.URL.String())
+	}
+
+	h.http = &HTTPLoader{Client: client, Header: l.Header}
+	return h
+}
+
+func (h *HostsLoader) check(uri string) error {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return err
+	}
+
+	if !h.hosts[strings.ToLower(u.Host)] && !h.hosts[strings.ToLower(u.Hostname())] {
+		return fmt.Errorf("%s: host '%s' is not allowed", uri, u.Host)
+	}
+	return nil
+}
+
+// Load sends GET request to the allowed host.
+func (h *HostsLoader) Load(uri string) (*Resource, error) {
+	return h.LoadLimited(uri, 0)
+}
+
+// LoadLimited sends GET request to the allowed host and reads the response up to limit bytes.
+func (h *HostsLoader) LoadLimited(uri string, limit int64) (*Resource, error) {
+	if err := h.check(uri); err != nil {
+		return nil, err
+	}
+	return h.http.LoadLimited(uri, limit)
+}
+
+// Version sends HEAD request to the allowed host.
+func (h *HostsLoader) Version(uri string) (string, error) {
+	if err := h.check(uri); err != nil {
+		return "", err
+	}
+	return h.http.Version(uri)
+}
+
+func httpVersion(resp *http.Response) string {
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		return etag
+	}
+	return resp.Header.Get("Last-Modified")
+}
+
+// GetURI returns json or yaml parsed object by URL or file link.
+func GetURI(filename string) (interface{}, error) {
+
+	var res *Resource
+	var err error
+
+	if IsURL(filename) {
+		res, err = (&HTTPLoader{}).Load(filename)
+	} else {
+		res, err = FileLoader{}.Load(filename)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return format.Decode(format.Detect(filename, res.ContentType, res.Body), res.Body)
+}
+
+func loadFile(filename string, limit int64) ([]byte, error) {
+
+	uri := filename
+	filename = strings.TrimPrefix(filename, "file://")
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadLimited(uri, file, limit)
 }
 
-// isValidUrl tests a string to determine if it is a url or not.
-func isURL(testURL string) bool {
+// IsURL tests a string to determine if it is a http(s) url or not.
+func IsURL(testURL string) bool {
 	//_, err := url.ParseRequestURI(toTest)
 	u, err := url.Parse(testURL)
 
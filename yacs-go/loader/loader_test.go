@@ -1,7 +1,14 @@
 package loader
 
 import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	. "gopkg.in/check.v1"
 )
@@ -25,28 +32,50 @@ type loaderTestSuite struct{}
 var _ = Suite(&loaderTestSuite{})
 
 func (s *loaderTestSuite) Test_loadFile_V01(c *C) {
-	body, err := loadFile("./my.json")
+	body, err := loadFile("./my.json", 0)
 	c.Assert(err, IsNil)
 	c.Assert(body, DeepEquals, testFileContent)
 }
 
 func (s *loaderTestSuite) Test_loadFile_V02(c *C) {
-	body, err := loadFile("file://my.json")
+	body, err := loadFile("file://my.json", 0)
 	c.Assert(err, IsNil)
 	c.Assert(body, DeepEquals, testFileContent)
 }
 
-func (s *loaderTestSuite) Test_isURL_V01(c *C) {
-	c.Assert(isURL("file://my.json"), Equals, false)
-	c.Assert(isURL("my.json"), Equals, false)
-	c.Assert(isURL("./my.json"), Equals, false)
-	c.Assert(isURL("file:///User/Ivan/my.json"), Equals, false)
-	c.Assert(isURL("./my.json"), Equals, false)
+func (s *loaderTestSuite) Test_LoadLimited(c *C) {
+	size := int64(len(testFileContent))
+
+	res, err := FileLoader{}.LoadLimited("my.json", size)
+	c.Assert(err, IsNil)
+	c.Assert(res.Body, DeepEquals, testFileContent)
+
+	_, err = FileLoader{}.LoadLimited("my.json", size-1)
+	c.Assert(err, DeepEquals, &TooLargeError{URI: "my.json", Limit: size - 1})
+
+	// The body isn't read up to the end.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(testFileContent)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	_, err = NewHTTPLoader(time.Second, nil).LoadLimited(server.URL+"/my.json", size-1)
+	c.Assert(err, ErrorMatches, ".*my.json: document is larger than .* bytes")
+}
+
+func (s *loaderTestSuite) Test_IsURL_V01(c *C) {
+	c.Assert(IsURL("file://my.json"), Equals, false)
+	c.Assert(IsURL("my.json"), Equals, false)
+	c.Assert(IsURL("./my.json"), Equals, false)
+	c.Assert(IsURL("file:///User/Ivan/my.json"), Equals, false)
+	c.Assert(IsURL("./my.json"), Equals, false)
 }
 
-func (s *loaderTestSuite) Test_isURL_V02(c *C) {
-	c.Assert(isURL("http://google.com/my.json"), Equals, true)
-	c.Assert(isURL("https://google.com/my.json"), Equals, true)
+func (s *loaderTestSuite) Test_IsURL_V02(c *C) {
+	c.Assert(IsURL("http://google.com/my.json"), Equals, true)
+	c.Assert(IsURL("https://google.com/my.json"), Equals, true)
 }
 
 func (s *loaderTestSuite) Test_GetURI_V01(c *C) {
@@ -60,3 +89,77 @@ func (s *loaderTestSuite) Test_GetURI_V02(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(body, DeepEquals, testFileJSON)
 }
+
+func (s *loaderTestSuite) Test_GetURI_YAML(c *C) {
+	body, err := GetURI("my.yaml")
+	c.Assert(err, IsNil)
+	c.Assert(body, DeepEquals, testFileJSON)
+}
+
+func (s *loaderTestSuite) Test_DirLoader(c *C) {
+	root := c.MkDir()
+	dir := filepath.Join(root, "configs")
+	c.Assert(os.MkdirAll(filepath.Join(dir, "sub"), 0777), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "sub", "in.json"), testFileContent, 0666), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(root, "out.json"), testFileContent, 0666), IsNil)
+	c.Assert(os.Symlink(filepath.Join(root, "out.json"), filepath.Join(dir, "link.json")), IsNil)
+	c.Assert(os.Symlink(filepath.Join(dir, "sub", "in.json"), filepath.Join(dir, "inner.json")), IsNil)
+
+	l := DirLoader{Dir: dir}
+
+	res, err := l.Load(filepath.Join(dir, "sub", "in.json"))
+	c.Assert(err, IsNil)
+	c.Assert(res.Body, DeepEquals, testFileContent)
+
+	_, err = l.Load("file://" + filepath.Join(dir, "inner.json"))
+	c.Assert(err, IsNil)
+
+	_, err = l.Version(filepath.Join(dir, "sub", "in.json"))
+	c.Assert(err, IsNil)
+
+	for _, uri := range []string{
+		filepath.Join(root, "out.json"),
+		filepath.Join(dir, "..", "out.json"),
+		filepath.Join(dir, "link.json"),
+		"file://" + filepath.Join(root, "out.json"),
+		"/etc/passwd",
+		dir + "-other/x.json",
+	} {
+		_, err = l.Load(uri)
+		c.Assert(err, ErrorMatches, ".*out of the root dir", Commentf(uri))
+		_, err = l.Version(uri)
+		c.Assert(err, ErrorMatches, ".*out of the root dir", Commentf(uri))
+	}
+}
+
+func (s *loaderTestSuite) Test_HostsLoader(c *C) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(testFileContent)
+	}))
+	defer other.Close()
+
+	allowed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, other.URL+"/my.json", http.StatusFound)
+			return
+		}
+		w.Write(testFileContent)
+	}))
+	defer allowed.Close()
+
+	host := strings.TrimPrefix(allowed.URL, "http://")
+	l := NewHostsLoader(NewHTTPLoader(time.Second, nil), host)
+
+	res, err := l.Load(allowed.URL + "/my.json")
+	c.Assert(err, IsNil)
+	c.Assert(res.Body, DeepEquals, testFileContent)
+
+	_, err = l.Load(allowed.URL + "/redirect")
+	c.Assert(err, ErrorMatches, ".*is not allowed")
+
+	_, err = l.Load(other.URL + "/my.json")
+	c.Assert(err, ErrorMatches, ".*host '.*' is not allowed")
+
+	_, err = NewHostsLoader(NewHTTPLoader(time.Second, nil)).Load(allowed.URL + "/my.json")
+	c.Assert(err, ErrorMatches, ".*is not allowed")
+}
@@ -1,16 +1,24 @@
 package myconst
 
 const (
+	// DeleteKeyName "@delete": At any level, keys or JSON pointers which are removed from the values of "@parent".
+	DeleteKeyName string = "@delete"
 	// DocKeyName "@doc": At any level, it is container for processing instructions.
 	DocKeyName string = "@doc"
 	// JSONRefKeyName "$ref": At any level, it is reference for includes or schemas.
 	JSONRefKeyName string = "$ref"
+	// LocksKeyName "locks" is used "@doc": what to do when a child overrides "@lock_names": "ignore", "warn" or "error".
+	LocksKeyName string = "locks"
 	// LockKeyName "@lock_names": At any level, don't allow values defined at this level to be overwritten.
 	LockKeyName string = "@lock_names"
+	// MergeKeyName "@merge": At any level, strategies of merging of arrays with "@parent": "append", "prepend", "replace", "by-key:<field>".
+	MergeKeyName string = "@merge"
 	// ParentKeyName "@parent": Treat this sub-structure as a parent and the containing structure as overrrides.
 	ParentKeyName string = "@parent"
 	// ResolveKeyName "resolve" is used "@doc".
 	ResolveKeyName string = "resolve"
 	// SchemaKeyName "@schemas": At any level, it is object with references to schemas.
 	SchemaKeyName string = "@schemas"
+	// UnsetKeyName "@unset" is alias of "@delete".
+	UnsetKeyName string = "@unset"
 )
@@ -8,15 +8,12 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
-	"regexp"
-	"strconv"
 	"strings"
 )
 
-var hasDigitalOnly *regexp.Regexp
-
-func init() {
-	hasDigitalOnly = regexp.MustCompile(`^[0-9]+$`)
+// Logger prints details of processing. The *log.Logger implements it.
+type Logger interface {
+	Printf(format string, v ...interface{})
 }
 
 // FileForProcess is object for stroing information processing of single file
@@ -84,7 +81,7 @@ func CreateDirIfNotExist(dir string, mode os.FileMode) error {
 	return nil
 }
 
-// URLDefrag splits uri to fragment.
+// URLDefrag splits uri to fragment. Scheme and host of remote URLs are kept.
 func URLDefrag(URI string) (string, string, error) {
 	u, err := url.Parse(URI)
 	if err != nil {
@@ -92,29 +89,52 @@ func URLDefrag(URI string) (string, string, error) {
 	}
 
 	fragment := u.Fragment
-	u.Scheme = ""
 	u.Fragment = ""
+
+	if IsRemoteURI(URI) {
+		return u.String(), fragment, nil
+	}
+
+	u.Scheme = ""
 	return filepath.Clean(u.String()), fragment, nil
 }
 
+// IsRemoteURI checks that uri has a scheme which isn't "file".
+// One letter schemes are Windows drives.
+func IsRemoteURI(uri string) bool {
+	u, err := url.Parse(uri)
+	return err == nil && len(u.Scheme) > 1 && u.Scheme != "file"
+}
+
+// IsWithinDir checks that the path is dir or inside of it. Both paths must be clean and of the same kind,
+// absolute or relative.
+func IsWithinDir(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
+
 // SaveJSONFile stores interface to json file.
 func SaveJSONFile(file string, data interface{}, mode os.FileMode) error {
 
-	dir, _ := filepath.Split(file)
-	err := CreateDirIfNotExist(dir, mode)
+	json, err := json.MarshalIndent(data, "", "    ")
 	if err != nil {
 		return err
 	}
 
-	json, err := json.MarshalIndent(data, "", "    ")
+	json = append(json, ([]byte("\n"))...)
+
+	return SaveFile(file, json, mode)
+}
+
+// SaveFile stores body to file. The dir of the file will be created if it doesn't exist.
+func SaveFile(file string, body []byte, mode os.FileMode) error {
+
+	dir, _ := filepath.Split(file)
+	err := CreateDirIfNotExist(dir, mode)
 	if err != nil {
 		return err
 	}
 
-	json = append(json, ([]byte("\n"))...)
-
-	err = ioutil.WriteFile(file, json, mode)
-	return err
+	return ioutil.WriteFile(file, body, mode)
 }
 
 // GetKeyFromIntefaceString returns string value from hash.
@@ -143,43 +163,6 @@ func DoesIntefaceHaveKey(node interface{}, key string) bool {
 	return find
 }
 
-// GetKeyFromAnyInteface returns value from hash by key and from array by index.
-func GetKeyFromAnyInteface(node interface{}, key string) (interface{}, bool) {
-
-	if node == nil {
-		return nil, false
-	}
-
-	switch node.(type) {
-
-	case map[string]interface{}:
-		if res, find := (node.(map[string]interface{}))[key]; find {
-			return res, find
-		}
-
-	case []interface{}:
-
-		iStr := hasDigitalOnly.FindString(key)
-		if iStr == "" {
-			return nil, false
-		}
-
-		i, err := strconv.Atoi(iStr)
-		if err != nil || i < 0 {
-			return nil, false
-		}
-
-		m := node.([]interface{})
-		if len(m) <= i {
-			return nil, false
-		}
-
-		return m[i], true
-	}
-
-	return nil, false
-}
-
 // GetKeyFromInteface returns value from hash by key.
 func GetKeyFromInteface(node interface{}, key string) (interface{}, bool) {
 
@@ -246,8 +229,8 @@ func ToListInterface(doc interface{}) []interface{} {
 		return doc.([]interface{})
 	case []string:
 		out := []interface{}{}
-		for k, v := range doc.([]string) {
-			out[k] = v
+		for _, v := range doc.([]string) {
+			out = append(out, v)
 		}
 		return out
 	}
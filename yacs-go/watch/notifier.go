@@ -0,0 +1,274 @@
+package watch
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// notifier reports changes of files in watched dirs.
+type notifier interface {
+	// watch replaces the list of watched dirs, they are not recursive.
+	watch(dirs []string) error
+	// changes gets a value when anything has been changed, changes are not queued.
+	changes() <-chan struct{}
+	close() error
+}
+
+// newNotifier returns fsnotify notifier or poller if fsnotify isn't available or Options.Poll is set.
+func newNotifier(opts Options, skip func(name string) bool) (notifier, error) {
+	if !opts.Poll {
+		n, err := newFSNotifier(opts, skip)
+		if err == nil {
+			return n, nil
+		}
+		opts.printf("warning: watch: %s, polling is used", err)
+	}
+	return newPoller(opts.Interval, skip), nil
+}
+
+// signal sends the change without blocking, a pending change is enough.
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+type fsNotifier struct {
+	w    *fsnotify.Watcher
+	dirs map[string]bool
+	ch   chan struct{}
+	done chan struct{}
+}
+
+func newFSNotifier(opts Options, skip func(name string) bool) (*fsNotifier, error) {
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	n := &fsNotifier{
+		w:    w,
+		dirs: map[string]bool{},
+		ch:   make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(n.done)
+		for {
+			select {
+			case ev, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if !skip(ev.Name) {
+					signal(n.ch)
+				}
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				// Overflow of the queue of events means lost changes.
+				opts.printf("warning: watch: %s", err)
+				signal(n.ch)
+			}
+		}
+	}()
+
+	return n, nil
+}
+
+func (n *fsNotifier) watch(dirs []string) error {
+
+	list := map[string]bool{}
+	for _, dir := range dirs {
+		list[dir] = true
+		if n.dirs[dir] {
+			continue
+		}
+		if err := n.w.Add(dir); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return fmt.Errorf("watch %s: %s", dir, err)
+		}
+		n.dirs[dir] = true
+	}
+
+	for dir := range n.dirs {
+		if !list[dir] {
+			// The dir may be removed already.
+			n.w.Remove(dir)
+			delete(n.dirs, dir)
+		}
+	}
+
+	return nil
+}
+
+func (n *fsNotifier) changes() <-chan struct{} {
+	return n.ch
+}
+
+func (n *fsNotifier) close() error {
+	err := n.w.Close()
+	<-n.done
+	return err
+}
+
+// poller compares states of files in watched dirs every interval.
+type poller struct {
+	mu    sync.Mutex
+	dirs  []string
+	state map[string]string
+	skip  func(name string) bool
+	ch    chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+func newPoller(interval time.Duration, skip func(name string) bool) *poller {
+
+	p := &poller{
+		skip: skip,
+		ch:   make(chan struct{}, 1),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				p.check()
+			}
+		}
+	}()
+
+	return p
+}
+
+// watch keeps the known state of already watched dirs, so their changes between checks are not lost.
+func (p *poller) watch(dirs []string) error {
+
+	p.mu.Lock()
+	old := map[string]bool{}
+	for _, dir := range p.dirs {
+		old[dir] = true
+	}
+	p.mu.Unlock()
+
+	added := []string{}
+	for _, dir := range dirs {
+		if !old[dir] {
+			added = append(added, dir)
+		}
+	}
+	state := p.scan(added)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.state == nil {
+		p.state = map[string]string{}
+	}
+	for name := range p.state {
+		if !watched(name, dirs) {
+			delete(p.state, name)
+		}
+	}
+	for name, v := range state {
+		p.state[name] = v
+	}
+
+	p.dirs = dirs
+	return nil
+}
+
+func watched(name string, dirs []string) bool {
+	dir := filepath.Dir(name)
+	for _, d := range dirs {
+		if d == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// check signals if the state of files has been changed since the previous check.
+func (p *poller) check() {
+
+	p.mu.Lock()
+	dirs := p.dirs
+	p.mu.Unlock()
+
+	state := p.scan(dirs)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !sameState(p.state, state) {
+		signal(p.ch)
+	}
+	p.state = state
+}
+
+// scan returns mtime and size of files of the dirs and names of subdirs.
+func (p *poller) scan(dirs []string) map[string]string {
+
+	state := map[string]string{}
+	for _, dir := range dirs {
+		list, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, info := range list {
+			name := filepath.Join(dir, info.Name())
+			switch {
+			case p.skip(name):
+			case info.IsDir():
+				// New subdirs have to be watched, their changes are seen by their own scan.
+				state[name] = "dir"
+			default:
+				state[name] = fmt.Sprintf("%d:%d", info.ModTime().UnixNano(), info.Size())
+			}
+		}
+	}
+	return state
+}
+
+func sameState(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, v := range a {
+		if b[name] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *poller) changes() <-chan struct{} {
+	return p.ch
+}
+
+func (p *poller) close() error {
+	close(p.stop)
+	<-p.done
+	return nil
+}
@@ -0,0 +1,186 @@
+package watch
+
+/*
+
+Watch keeps results of the dir up to date: files are processed again when they
+or their "$ref", "@parent" and "@schemas" documents are changed.
+
+Example usage:
+
+	ctx, cancel := context.WithCancel(context.Background())
+	...
+	err := watch.Run(ctx, helper.NewProcessor(), "./configs", "./out", watch.Options{
+		Batch:    batch.Options{Workers: 4, Format: format.JSON},
+		OnReport: func(r *batch.Report) { fmt.Println(r.Processed, "files are processed") },
+	})
+
+The first build processes the files which have been changed since the previous build,
+each next build is started by changes of the files. Only affected results are made again,
+dependencies are stored in the manifest of the out dir, see batch.Options.Manifest.
+
+Changes are found by inotify and the like via fsnotify. If it's not available or Options.Poll is set,
+watched dirs are checked every Options.Interval.
+
+*/
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/iostrovok/yacs-go/yacs-go/batch"
+	"github.com/iostrovok/yacs-go/yacs-go/helper"
+	"github.com/iostrovok/yacs-go/yacs-go/utils"
+)
+
+const (
+	// DefaultInterval is the period of polling if Options.Interval isn't set.
+	DefaultInterval = time.Second
+	// DefaultDelay is the quiet time after the last change if Options.Delay isn't set.
+	DefaultDelay = 100 * time.Millisecond
+)
+
+// Options of watching.
+type Options struct {
+	// Batch are options of each build. Manifest is outDir/batch.ManifestName if it's empty.
+	Batch batch.Options
+	// Poll turns on polling instead of notifications of the system.
+	Poll bool
+	// Interval is the period of polling.
+	Interval time.Duration
+	// Delay is the quiet time after the last change, so a burst of changes starts single build.
+	Delay time.Duration
+	// Logger prints problems of watching, it may be nil.
+	Logger utils.Logger
+	// OnReport is called after each build. It may be nil.
+	OnReport func(report *batch.Report)
+}
+
+func (opts Options) printf(text string, args ...interface{}) {
+	if opts.Logger != nil {
+		opts.Logger.Printf(text, args...)
+	}
+}
+
+// Run builds results of inDir to outDir and rebuilds them on changes until ctx is canceled.
+// The cache of p is reset before each build, so changed documents are never served from it.
+func Run(ctx context.Context, p *helper.Processor, inDir, outDir string, opts Options) error {
+
+	if opts.Batch.Manifest == "" {
+		opts.Batch.Manifest = filepath.Join(outDir, batch.ManifestName)
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	if opts.Delay <= 0 {
+		opts.Delay = DefaultDelay
+	}
+
+	absOut, err := filepath.Abs(outDir)
+	if err != nil {
+		return err
+	}
+
+	// Results and the manifest are written to outDir, which may be inside of inDir.
+	skip := func(name string) bool {
+		abs, err := filepath.Abs(name)
+		return err == nil && utils.IsWithinDir(abs, absOut)
+	}
+
+	n, err := newNotifier(opts, skip)
+	if err != nil {
+		return err
+	}
+	defer n.close()
+
+	for {
+		p.ResetCache()
+
+		all, err := utils.FindAllFiles(inDir, outDir, "")
+		if err != nil {
+			return err
+		}
+
+		files := all[:0]
+		for _, bf := range all {
+			if !skip(bf.From) {
+				files = append(files, bf)
+			}
+		}
+
+		report := batch.Run(ctx, p, files, opts.Batch)
+		if opts.OnReport != nil {
+			opts.OnReport(report)
+		}
+
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		dirs, err := watchDirs(inDir, absOut, report.Dependencies)
+		if err != nil {
+			return err
+		}
+
+		if err := n.watch(dirs); err != nil {
+			return err
+		}
+
+		if !wait(ctx, n.changes(), opts.Delay) {
+			return nil
+		}
+	}
+}
+
+// wait returns true after changes and the quiet delay, false if ctx is canceled.
+func wait(ctx context.Context, changes <-chan struct{}, delay time.Duration) bool {
+
+	var quiet <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-changes:
+			quiet = time.After(delay)
+		case <-quiet:
+			return true
+		}
+	}
+}
+
+// watchDirs returns inDir with all its subdirs except outDir and dirs of dependencies.
+// Dirs are watched instead of files, so new files and files which are saved by renaming are seen too.
+func watchDirs(inDir, absOut string, deps []string) ([]string, error) {
+
+	list := map[string]bool{}
+
+	err := filepath.Walk(inDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if abs, err := filepath.Abs(path); err == nil && utils.IsWithinDir(abs, absOut) {
+			return filepath.SkipDir
+		}
+		list[filepath.Clean(path)] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range deps {
+		list[filepath.Dir(file)] = true
+	}
+
+	out := make([]string, 0, len(list))
+	for dir := range list {
+		out = append(out, dir)
+	}
+	sort.Strings(out)
+	return out, nil
+}
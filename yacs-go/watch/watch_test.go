@@ -0,0 +1,116 @@
+package watch_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/iostrovok/yacs-go/yacs-go/batch"
+	"github.com/iostrovok/yacs-go/yacs-go/format"
+	"github.com/iostrovok/yacs-go/yacs-go/helper"
+	"github.com/iostrovok/yacs-go/yacs-go/watch"
+
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func Test(t *testing.T) { TestingT(t) }
+
+type watchTestSuite struct{}
+
+var _ = Suite(&watchTestSuite{})
+
+func write(c *C, file, body string) {
+	c.Assert(os.MkdirAll(filepath.Dir(file), 0777), IsNil)
+	c.Assert(ioutil.WriteFile(file, []byte(body), 0666), IsNil)
+}
+
+func nextReport(c *C, reports chan *batch.Report) *batch.Report {
+	select {
+	case r := <-reports:
+		return r
+	case <-time.After(10 * time.Second):
+		c.Fatal("no build after changes")
+	}
+	return nil
+}
+
+func (s *watchTestSuite) check(c *C, poll bool) {
+	root := c.MkDir()
+	in, out, common := filepath.Join(root, "in"), filepath.Join(root, "in", "out"), filepath.Join(root, "common")
+
+	write(c, filepath.Join(common, "parent.json"), `{"a": 1, "b": 1}`)
+	write(c, filepath.Join(in, "child.json"), `{"@parent": {"$ref": "../common/parent.json"}, "b": 2}`)
+	write(c, filepath.Join(in, "other.json"), `{"c": 3}`)
+
+	reports := make(chan *batch.Report, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+
+	go func() {
+		done <- watch.Run(ctx, helper.NewProcessor(), in, out, watch.Options{
+			Batch:    batch.Options{Format: format.JSONCompact},
+			Poll:     poll,
+			Interval: 20 * time.Millisecond,
+			Delay:    20 * time.Millisecond,
+			OnReport: func(r *batch.Report) { reports <- r },
+		})
+	}()
+
+	r := nextReport(c, reports)
+	c.Assert(r.Processed, Equals, 2)
+	c.Assert(r.Err(), IsNil)
+
+	// Changes of the parent outside of the dir rebuild the child only.
+	time.Sleep(50 * time.Millisecond)
+	write(c, filepath.Join(common, "parent.json"), `{"a": 10, "b": 1}`)
+
+	r = nextReport(c, reports)
+	c.Assert(r.Processed, Equals, 1)
+	c.Assert(r.Skipped, Equals, 1)
+
+	body, err := ioutil.ReadFile(filepath.Join(out, "child.json"))
+	c.Assert(err, IsNil)
+	c.Assert(string(body), Equals, "{\"a\":10,\"b\":2}\n")
+
+	// New files in new subdirs are found.
+	write(c, filepath.Join(in, "sub", "new.json"), `{"d": 4}`)
+	for r = nextReport(c, reports); r.Total < 3; {
+		r = nextReport(c, reports)
+	}
+	c.Assert(r.Processed, Equals, 1)
+	c.Assert(r.Skipped, Equals, 2)
+
+	// The broken parent is still watched, its fix rebuilds the child.
+	time.Sleep(50 * time.Millisecond)
+	write(c, filepath.Join(common, "parent.json"), `{"a": `)
+	for r = nextReport(c, reports); len(r.Failures) == 0; {
+		r = nextReport(c, reports)
+	}
+	c.Assert(r.Failures[0].File, Equals, filepath.Join(in, "child.json"))
+
+	time.Sleep(50 * time.Millisecond)
+	write(c, filepath.Join(common, "parent.json"), `{"a": 20, "b": 1}`)
+	for r = nextReport(c, reports); r.Err() != nil; {
+		r = nextReport(c, reports)
+	}
+	c.Assert(r.Processed, Equals, 1)
+
+	body, err = ioutil.ReadFile(filepath.Join(out, "child.json"))
+	c.Assert(err, IsNil)
+	c.Assert(string(body), Equals, "{\"a\":20,\"b\":2}\n")
+
+	cancel()
+	c.Assert(<-done, IsNil)
+}
+
+func (s *watchTestSuite) Test_Run_Notify(c *C) {
+	s.check(c, false)
+}
+
+func (s *watchTestSuite) Test_Run_Poll(c *C) {
+	s.check(c, true)
+}
@@ -7,31 +7,71 @@ Run YACS processing via the command line.
 */
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"runtime"
-	"sync"
+	"sort"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/iostrovok/yacs-go/yacs-go/batch"
 	"github.com/iostrovok/yacs-go/yacs-go/diff"
+	"github.com/iostrovok/yacs-go/yacs-go/format"
 	"github.com/iostrovok/yacs-go/yacs-go/helper"
+	"github.com/iostrovok/yacs-go/yacs-go/jsonpointer"
+	"github.com/iostrovok/yacs-go/yacs-go/jsonschema"
 	"github.com/iostrovok/yacs-go/yacs-go/loader"
 	"github.com/iostrovok/yacs-go/yacs-go/utils"
+	"github.com/iostrovok/yacs-go/yacs-go/watch"
 )
 
+// headerFlags collects "Key: Value" from repeated -http-header flags.
+type headerFlags []string
+
+func (h *headerFlags) String() string {
+	return strings.Join(*h, ", ")
+}
+
+func (h *headerFlags) Set(value string) error {
+	if !strings.Contains(value, ":") {
+		return fmt.Errorf("header must be 'Key: Value', got '%s'", value)
+	}
+	*h = append(*h, value)
+	return nil
+}
+
 type container struct {
 	command, outDIR, inDIR           string
 	sourceFile, copmareFile, outFile string
+	baseDIR                          string
+	path                             string
 	verbose, quiet                   bool
+	patch                            bool
 	help                             bool
 	needResolution                   bool
 	needInheritance                  bool
 	needValidation                   bool
+	processor                        *helper.Processor
+	httpTimeout                      time.Duration
+	maxRefDepth                      int
+	format                           format.Format
+	lockMode                         helper.LockMode
+	httpHeaders                      headerFlags
 	countCUPs                        int
+	failFast                         bool
+	incremental                      bool
+	check                            batch.CheckMode
+	poll                             bool
+	pollInterval                     time.Duration
 	mode                             os.FileMode
-	wg                               *sync.WaitGroup
-	workFiles                        chan utils.FileForProcess
 }
 
 func (con *container) checkOutDir() {
@@ -49,7 +89,6 @@ func (con *container) checkOutDir() {
 func main() {
 
 	con := &container{
-		wg:        &sync.WaitGroup{},
 		mode:      os.FileMode(0777),
 		countCUPs: 4,
 	}
@@ -59,20 +98,42 @@ func main() {
 	}
 
 	var skipResolution, skipInheritance, skipValidation bool
+	var outFormat, lockMode, checkMode string
 
 	flag.BoolVar(&con.help, "help", false, `View help message.`)
-	flag.StringVar(&con.command, "command", "", `What are we doing? May by "batchdir", "onefile", "compare"`)
-	flag.StringVar(&con.sourceFile, "file", "", `File which will be processed.`)
+	flag.StringVar(&con.command, "command", "", `What are we doing? May by "batchdir", "watch", "onefile", "compare", "explain"`)
+	flag.StringVar(&con.sourceFile, "file", "", `File which will be processed. "-" is stdin for "onefile".`)
 	flag.StringVar(&con.copmareFile, "copmarefile", "", `File for copmare with 'file'. It's used with 'file' in the same time.`)
-	flag.StringVar(&con.outFile, "outfile", "", `File for storing result. It's used with 'file' in the same time.`)
+	flag.StringVar(&con.outFile, "outfile", "", `File for storing result. It's used with 'file' in the same time. "-" or empty value is stdout.`)
+	flag.StringVar(&con.baseDIR, "basedir", "", `Dir or URL for relative references of the document from stdin. It's used with -file=-. (default current dir)`)
+
+	flag.StringVar(&con.path, "path", "", `JSON pointer of the value which is explained, all values are explained by default. It's used with "explain".`)
+
+	flag.BoolVar(&con.patch, "patch", false, `Print JSON Patch (RFC 6902) which turns 'copmarefile' into processed 'file'. It's used with "compare". (default "false")`)
 
 	flag.StringVar(&con.outDIR, "outdir", "", `Dir for storing result. Dir will be created if it doesn't exist.`)
 	flag.StringVar(&con.inDIR, "indir", "", `Dir (and all subdirs) which will be processed.`)
+	flag.StringVar(&outFormat, "format", string(format.JSON), `Format of result: json, json-compact, yaml, toml, env, properties.`)
 
 	flag.BoolVar(&skipResolution, "skip-resolution", false, `Skip reference resolution step. (default \"false\")`)
 	flag.BoolVar(&skipInheritance, "skip-inheritance", false, `Skip inheritance step. (default \"false\")`)
 	flag.BoolVar(&skipValidation, "skip-validation", false, `Skip schema validation step. (default "false")`)
 
+	flag.DurationVar(&con.httpTimeout, "http-timeout", loader.DefaultHTTPTimeout, `Timeout of loading of http(s) references.`)
+	flag.Var(&con.httpHeaders, "http-header", `Header "Key: Value" for loading of http(s) references. May be repeated.`)
+
+	flag.StringVar(&lockMode, "locks", helper.LockIgnore.String(), `What to do when a child overrides "@lock_names" of the parent: ignore, warn, error. "@doc": {"locks": "..."} of the document is stronger.`)
+
+	flag.IntVar(&con.maxRefDepth, "max-ref-depth", helper.DefaultMaxRefDepth, `Max length of chain of references. Zero means no limit.`)
+
+	flag.BoolVar(&con.failFast, "fail-fast", false, `Stop "batchdir" on the first failed file. All files are processed and failures are listed at the end by default. (default "false")`)
+
+	flag.BoolVar(&con.incremental, "incremental", false, `Process only files which have been changed since the previous "batchdir" with their "$ref", "@parent" and "@schemas". Dependencies are stored in the manifest `+batch.ManifestName+` of 'outdir'. (default "false")`)
+	flag.StringVar(&checkMode, "check", string(batch.CheckMtime), `How changes of files are found by "incremental": mtime (time of modification and size), hash (SHA-256 of content).`)
+
+	flag.BoolVar(&con.poll, "poll", false, `Check files every 'poll-interval' instead of notifications of the system. It's used with "watch". (default "false")`)
+	flag.DurationVar(&con.pollInterval, "poll-interval", watch.DefaultInterval, `Period of polling. It's used with "watch".`)
+
 	flag.BoolVar(&con.verbose, "verbose", false, `Shows details about the results of running. (default "false")`)
 	flag.BoolVar(&con.quiet, "quiet", false, `Silent operation. (default "false")`)
 
@@ -82,6 +143,25 @@ func main() {
 	con.needInheritance = !skipInheritance
 	con.needValidation = !skipValidation
 
+	f, err := format.Parse(outFormat)
+	if err != nil {
+		con.printError("%s", err)
+		os.Exit(2)
+	}
+	con.format = f
+
+	con.lockMode, err = helper.ParseLockMode(lockMode)
+	if err != nil {
+		con.printError("%s", err)
+		os.Exit(2)
+	}
+
+	con.check, err = batch.ParseCheckMode(checkMode)
+	if err != nil {
+		con.printError("%s", err)
+		os.Exit(2)
+	}
+
 	if con.help {
 		con.viewhelp()
 		return
@@ -90,10 +170,14 @@ func main() {
 	switch con.command {
 	case "batchdir":
 		con.batchdir()
+	case "watch":
+		con.watch()
 	case "onefile":
 		con.onefile()
 	case "compare":
 		con.compare()
+	case "explain":
+		con.explain()
 	default:
 		con.viewhelp()
 	}
@@ -101,21 +185,48 @@ func main() {
 
 func (con *container) viewhelp() {
 
-	fmt.Println(`
+	fmt.Print(`
+  -format string
+        Format of result: json, json-compact, yaml, toml, env, properties. (default "json")
+        Batch mode changes extension .json, .yaml and .yml of result files to match the format.
   -help
         View help message.
+  -basedir string
+        Dir or URL for relative references of the document from stdin. It's used with -file=-. (default current dir)
+  -check string
+        How changes of files are found by "incremental": mtime (time of modification and size), hash (SHA-256 of content). (default "mtime")
   -command string
-        What are we doing? May by "batchdir", "onefile", "compare"
+        What are we doing? May by "batchdir", "watch", "onefile", "compare", "explain"
   -copmarefile string
         File for copmare with 'file'. It's used with 'file' in the same time.
+  -fail-fast
+        Stop "batchdir" on the first failed file. All files are processed and failures are listed at the end by default. (default "false")
   -file string
-        File which will be processed.
+        File which will be processed. "-" is stdin for "onefile".
+  -http-header value
+        Header "Key: Value" for loading of http(s) references. May be repeated.
+  -http-timeout duration
+        Timeout of loading of http(s) references. (default 30s)
+  -incremental
+        Process only files which have been changed since the previous "batchdir" with their "$ref", "@parent" and "@schemas". Dependencies are stored in the manifest .yacs-manifest.json of 'outdir'. (default "false")
   -indir string
         Dir (and all subdirs) which will be processed.
+  -locks string
+        What to do when a child overrides "@lock_names" of the parent: ignore, warn, error. "@doc": {"locks": "..."} of the document is stronger. (default "ignore")
+  -max-ref-depth int
+        Max length of chain of references. Zero means no limit. (default 64)
+  -poll
+        Check files every 'poll-interval' instead of notifications of the system. It's used with "watch". (default "false")
+  -poll-interval duration
+        Period of polling. It's used with "watch". (default 1s)
   -outdir string
         Dir for storing result. Dir will be created if it doesn't exist.
   -outfile string
-        File for storing result. It's used with 'file' in the same time.
+        File for storing result. It's used with 'file' in the same time. "-" or empty value is stdout.
+  -path string
+        JSON pointer of the value which is explained, all values are explained by default. It's used with "explain".
+  -patch
+        Print JSON Patch (RFC 6902) which turns 'copmarefile' into processed 'file'. It's used with "compare". (default "false")
   -quiet
         Silent operation. (default "false")
   -skip-inheritance
@@ -132,7 +243,14 @@ Example:
 > ./bin/yacsgo -help
 > ./bin/yacsgo -verbose=t -command=batchdir -indir=./json-files/ -outdir=./test-out/
 > ./bin/yacsgo -verbose=t -command=onefile --file=./mine.json -outfile=./out.json
+> ./bin/yacsgo -command=batchdir -indir=./yaml-files/ -outdir=./test-out/ -format=properties
+> ./bin/yacsgo -command=batchdir -incremental -check=hash -indir=./json-files/ -outdir=./test-out/
+> ./bin/yacsgo -verbose=t -command=watch -indir=./json-files/ -outdir=./test-out/
+> ./bin/yacsgo -command=onefile -locks=error --file=./mine.json -outfile=./out.json
+> cat ./mine.yaml | ./bin/yacsgo -command=onefile -file=- -basedir=./configs/ -format=env > app.env
 > ./bin/yacsgo -verbose=t -command=compare -file=./mine.json -copmarefile=./yours.json
+> ./bin/yacsgo -command=explain -file=./mine.json -path=/market-id
+> ./bin/yacsgo -command=compare -patch -file=./mine.json -copmarefile=./yours.json > patch.json
 
 `)
 
@@ -142,11 +260,9 @@ func (con *container) batchdir() {
 
 	con.print("... command: %s\n    outdir: %s\n    indir: %s", con.command, con.outDIR, con.inDIR)
 
-	startTime := time.Now()
-
 	// checkOutDir(con.outDIR, con.mode)
 	con.checkOutDir()
-	con.workFiles = make(chan utils.FileForProcess, con.countCUPs*3)
+	con.processor = con.newProcessor(false)
 
 	// Preparing...
 	list, err := utils.FindAllFiles(con.inDIR, con.outDIR, "")
@@ -157,78 +273,186 @@ func (con *container) batchdir() {
 
 	con.print("Total %d files for processing", len(list))
 
-	for i := 0; i < con.countCUPs; i++ {
-		con.process(i)
+	opts := con.batchOptions()
+	opts.Done = func(bf utils.FileForProcess, err error) {
+		if err != nil {
+			con.print("%d] %s FAILED", bf.Num, bf.From)
+			return
+		}
+		con.print("%d] %s ===>>> %s", bf.Num, bf.From, bf.To)
 	}
 
-	// Pushs files for processing to goroutins
-	con.pushFiles(list)
+	report := batch.Run(context.Background(), con.processor, list, opts)
 
-	// Closes our end of channel
-	close(con.workFiles)
+	con.print("... command: %s\n    outdir: %s\n    indir: %s", con.command, con.outDIR, con.inDIR)
+	con.print("Total %d files have been processed with %d threads in %.0f seconds", report.Processed, opts.Workers, report.Duration.Seconds())
+	if con.incremental {
+		con.print("%d files are up to date", report.Skipped)
+	}
 
-	// It's waiting for goroutines are working.
-	con.wg.Wait()
+	con.summary(report)
+}
+
+// watch rebuilds changed results of inDIR until SIGINT or SIGTERM.
+func (con *container) watch() {
 
 	con.print("... command: %s\n    outdir: %s\n    indir: %s", con.command, con.outDIR, con.inDIR)
-	con.print("Total %d files have been processed with %d threads in %.0f seconds", len(list), con.countCUPs, time.Now().Sub(startTime).Seconds())
+
+	con.checkOutDir()
+	con.processor = con.newProcessor(false)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	con.incremental = true
+	opts := con.batchOptions()
+	opts.Done = func(bf utils.FileForProcess, err error) {
+		if err == nil {
+			con.print("%s ===>>> %s", bf.From, bf.To)
+		}
+	}
+
+	err := watch.Run(ctx, con.processor, con.inDIR, con.outDIR, watch.Options{
+		Batch:    opts,
+		Poll:     con.poll,
+		Interval: con.pollInterval,
+		Logger:   printer(con.printSimple),
+		OnReport: func(report *batch.Report) {
+			if report.Canceled {
+				return
+			}
+			con.printSimple("%s: %d files have been processed, %d are up to date, %d have failed",
+				time.Now().Format("15:04:05"), report.Processed, report.Skipped, len(report.Failures))
+			for _, f := range report.Failures {
+				con.printSimple("   %s\n   stage: %s\n   error: %s", f.File, f.Stage, strings.Replace(f.Err.Error(), "\n", "\n   ", -1))
+			}
+		},
+	})
+
+	if err != nil {
+		con.fail(err)
+	}
+}
+
+// summary prints failures of the batch and exits with code 1 if there are any.
+func (con *container) summary(report *batch.Report) {
+
+	if report.Err() == nil {
+		return
+	}
+
+	con.printError("\n%s, %d written:", report.Err(), report.Written)
+	for i, f := range report.Failures {
+		con.printError("%d. %s\n   stage: %s\n   error: %s", i+1, f.File, f.Stage, strings.Replace(f.Err.Error(), "\n", "\n   ", -1))
+	}
+	os.Exit(1)
 }
 
-func (con *container) pushFiles(list []utils.FileForProcess) {
-	// Pushs files for processing to goroutins
-	for i, bf := range list {
-		bf.Num = i + 1
-		con.workFiles <- bf
+// batchOptions returns options of processing of files from the command line flags.
+func (con *container) batchOptions() batch.Options {
+	opts := batch.Options{
+		Workers:  con.countCUPs,
+		Format:   con.format,
+		Mode:     con.mode,
+		FailFast: con.failFast,
+		Logger:   printer(con.printSimple),
 	}
+
+	if con.incremental {
+		opts.Manifest = filepath.Join(con.outDIR, batch.ManifestName)
+		opts.Check = con.check
+		opts.Settings = con.settings()
+	}
+
+	return opts
 }
 
+// settings describe flags which change results, results are made again if they are changed.
+func (con *container) settings() string {
+	return fmt.Sprintf("format=%s resolve=%t inherit=%t validate=%t locks=%s max-ref-depth=%d",
+		con.format, con.needResolution, con.needInheritance, con.needValidation, con.lockMode, con.maxRefDepth)
+}
+
+// printer is utils.Logger which prints lines by container.
+type printer func(text string, args ...interface{})
+
+func (p printer) Printf(text string, args ...interface{}) {
+	p(text, args...)
+}
+
+// print and printSimple write progress to stderr, so they don't mix with results in stdout.
 func (con *container) print(text string, args ...interface{}) {
 	if con.verbose && !con.quiet {
-		fmt.Printf(text+"\n", args...)
+		fmt.Fprintf(os.Stderr, text+"\n", args...)
 	}
 }
 
 func (con *container) printSimple(text string, args ...interface{}) {
 	if !con.quiet {
-		fmt.Printf(text+"\n", args...)
+		fmt.Fprintf(os.Stderr, text+"\n", args...)
 	}
 }
 
-func (con *container) process(number int) {
-	con.wg.Add(1)
-	go con._process(number)
+// printResult writes the result of the command to stdout, "-quiet" doesn't hide it.
+func (con *container) printResult(text string, args ...interface{}) {
+	fmt.Printf(text+"\n", args...)
 }
 
-func (con *container) _process(number int) {
-	defer con.wg.Done()
-	for {
-		select {
-		case bf, ok := <-con.workFiles:
-			if !ok {
-				con.print("Thread %d has finished", number)
-				return
-			}
-
-			err := con.processOneFile(bf.From, bf.To, false)
-			if err != nil {
-				// Any error should break process and stop all goroutines.
-				// Other way we may not find error in tons of logs lines.
-				panic(err)
-			}
-			con.print("%d] %s ===>>> %s", bf.Num, bf.From, bf.To)
-		}
-	}
+// printError writes errors to stderr, "-quiet" doesn't hide them.
+func (con *container) printError(text string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, text+"\n", args...)
 }
 
+// onefile processes single document, "-" is stdin for 'file' and stdout for 'outfile'.
 func (con *container) onefile() {
 
 	con.print("... command: %s\n    file: %s\n    outfile: %s", con.command, con.sourceFile, con.outFile)
 
-	if err := con.processOneFile(con.sourceFile, con.outFile, con.verbose && !con.quiet); err != nil {
-		panic(err)
+	fromStdin := con.sourceFile == "-"
+	toStdout := con.outFile == "" || con.outFile == "-"
+
+	if !fromStdin && !toStdout {
+		con.processor = con.newProcessor(con.verbose && !con.quiet)
+		if err := batch.ProcessFile(con.processor, con.sourceFile, con.outFile, con.batchOptions()); err != nil {
+			con.fail(err)
+		}
+		con.printSimple("Out: %s ===>>> %s", con.sourceFile, con.outFile)
+		return
+	}
+
+	var res *helper.Output
+	var err error
+	if fromStdin {
+		con.processor = con.newProcessor(con.verbose && !con.quiet, helper.WithBaseDir(con.baseDIR))
+		res, err = con.processor.ProcessReader(os.Stdin)
+	} else {
+		con.processor = con.newProcessor(con.verbose && !con.quiet)
+		res, err = con.processor.ProcessURI(con.sourceFile)
+	}
+	if err != nil {
+		con.fail(err)
+	}
+
+	for _, w := range res.Warnings {
+		con.printSimple("warning: %s: %s", con.sourceFile, w)
+	}
+
+	body, err := format.Encode(con.format, res.Doc)
+	if err != nil {
+		con.fail(err)
+	}
+
+	if !toStdout {
+		if err := utils.SaveFile(con.outFile, body, con.mode); err != nil {
+			con.fail(err)
+		}
+		con.printSimple("Out: %s ===>>> %s", con.sourceFile, con.outFile)
+		return
 	}
 
-	con.printSimple("Out: %s ===>>> %s", con.sourceFile, con.outFile)
+	if _, err := os.Stdout.Write(body); err != nil {
+		con.fail(err)
+	}
 }
 
 func (con *container) compare() {
@@ -243,36 +467,134 @@ func (con *container) compare() {
 
 	con.print("Start processing the %s...", con.copmareFile)
 
-	processedDoc, err := helper.Process(con.sourceFile, con.needResolution, con.needInheritance, con.needValidation, false)
+	res, err := con.newProcessor(false).ProcessURI(con.sourceFile)
 	if err != nil {
-		panic(err)
+		con.fail(err)
 	}
 
-	diffres := diff.Diff(comparebody, processedDoc)
+	if con.patch {
+		patch, err := json.MarshalIndent(diff.Patch(diff.Changes(comparebody, res.Doc)), "", "    ")
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println(string(patch))
+		return
+	}
+
+	diffres := diff.Diff(comparebody, res.Doc)
 
 	if !con.verbose {
 		// print here a short message
-		con.printSimple("The files have %d differences\n", len(diffres))
+		con.printResult("The files have %d differences\n", len(diffres))
 		return
 	}
 
-	con.printSimple("\n\nComparison result...\n")
+	con.printResult("\n\nComparison result...\n")
 
 	if len(diffres) == 0 {
-		con.printSimple("Congratulation! The files are equal.\n")
+		con.printResult("Congratulation! The files are equal.\n")
 		return
 	}
 
 	for i, v := range diffres {
-		con.printSimple("%d. %v\n", i+1, v)
+		con.printResult("%d. %v\n", i+1, v)
 	}
 }
 
-func (con *container) processOneFile(from, to string, verbose bool) error {
-	processedDoc, err := helper.Process(from, con.needResolution, con.needInheritance, con.needValidation, verbose)
+func (con *container) explain() {
+
+	con.print("... command: %s\n    file: %s\n    path: %s", con.command, con.sourceFile, con.path)
+
+	res, err := con.newProcessor(false, helper.WithProvenance(true)).ProcessURI(con.sourceFile)
 	if err != nil {
-		return err
+		con.fail(err)
+	}
+
+	paths := []string{con.path}
+	if con.path == "" {
+		paths = make([]string, 0, len(res.Provenance))
+		for p := range res.Provenance {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+	}
+
+	for _, p := range paths {
+		chain, find := res.Provenance[p]
+		if !find {
+			con.printError("The value '%s' is not found in the result", p)
+			os.Exit(1)
+		}
+
+		value, err := jsonpointer.Get(res.Doc, jsonpointer.MustParse(p))
+		if err != nil {
+			panic(err)
+		}
+		body, err := json.Marshal(value)
+		if err != nil {
+			panic(err)
+		}
+
+		fmt.Printf("%s = %s\n", p, body)
+		for i, src := range chain {
+			fmt.Printf("  %d. %-8s %s#%s\n", i+1, src.Stage, src.URI, src.Pointer)
+		}
+	}
+}
+
+// fail prints the error of processing of single document and exits with code 1.
+func (con *container) fail(err error) {
+
+	var lockErr *helper.LockedValueError
+	if errors.As(err, &lockErr) {
+		con.printError("The document overrides locked value: %s", err)
+		os.Exit(1)
+	}
+
+	var failures jsonschema.ValidationError
+	if !errors.As(err, &failures) {
+		con.printError("ERROR: %s", err)
+		os.Exit(1)
+	}
+
+	con.printError("The document is not valid, %d error(s):", len(failures))
+	for i, f := range failures {
+		con.printError("%d. schema: %s\n   pointer: '%s'\n   path: '%s'\n   error: %s", i+1, f.SchemaKey, f.Pointer, f.Path, f.Description)
+		if f.Line > 0 {
+			con.printError("   source: %s:%d:%d", f.URI, f.Line, f.Column)
+		}
+	}
+	os.Exit(1)
+}
+
+// newProcessor returns processor with stages from the command line flags.
+func (con *container) newProcessor(verbose bool, extra ...helper.Option) *helper.Processor {
+
+	var stages helper.Stage
+	if con.needResolution {
+		stages |= helper.StageResolve
+	}
+	if con.needInheritance {
+		stages |= helper.StageInherit
+	}
+	if con.needValidation {
+		stages |= helper.StageValidate
+	}
+
+	opts := []helper.Option{
+		helper.WithStages(stages),
+		helper.WithHTTPTimeout(con.httpTimeout),
+		helper.WithLimits(helper.Limits{MaxRefDepth: con.maxRefDepth}),
+		helper.WithLockMode(con.lockMode),
+	}
+
+	for _, h := range con.httpHeaders {
+		kv := strings.SplitN(h, ":", 2)
+		opts = append(opts, helper.WithHTTPHeader(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])))
+	}
+	if verbose {
+		opts = append(opts, helper.WithLogger(log.New(os.Stderr, "", 0)))
 	}
 
-	return utils.SaveJSONFile(to, processedDoc, con.mode)
+	return helper.NewProcessor(append(opts, extra...)...)
 }